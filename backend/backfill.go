@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// maxBackfillDays bounds one backfill request; longer ranges are split by
+// the caller
+const maxBackfillDays = 5 * 366
+
+// Backfill modes: what to do with a date that already has a snapshot
+const (
+	BackfillSkip    = "skip"
+	BackfillReplace = "replace"
+)
+
+// BackfillResult reports what a history backfill wrote, by Colombo date
+type BackfillResult struct {
+	UID        string   `json:"uid"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Mode       string   `json:"mode"`
+	DryRun     bool     `json:"dryRun,omitempty"`
+	Written    []string `json:"written"`    // new snapshots
+	Replaced   []string `json:"replaced"`   // existing snapshots overwritten
+	Skipped    []string `json:"skipped"`    // existing snapshots kept
+	Incomplete []string `json:"incomplete"` // written with holdings that had no price
+}
+
+// historicalQuotes values every symbol as of the close of day from its
+// price history: that day's record is live, an earlier one last-known.
+// history holds each symbol's records oldest first.
+func historicalQuotes(history map[string][]PriceRecord, day string, closeAt time.Time) map[string]PriceQuote {
+	quotes := make(map[string]PriceQuote, len(history))
+	for symbol, records := range history {
+		// first record after day, so the one before it is the latest on or before
+		i := sort.Search(len(records), func(i int) bool { return records[i].Date > day })
+		if i == 0 {
+			continue
+		}
+		rec := records[i-1]
+		if rec.Date == day {
+			quotes[symbol] = PriceQuote{
+				Price:         NewDecimalFromFloat(rec.Price),
+				PreviousClose: NewDecimalFromFloat(rec.PreviousClose),
+				Status:        PriceLive,
+				AsOf:          closeAt.Format(time.RFC3339),
+			}
+			continue
+		}
+		quotes[symbol] = PriceQuote{Price: NewDecimalFromFloat(rec.Price), Status: PriceLastKnown, AsOf: rec.SourceTimestamp}
+	}
+	return quotes
+}
+
+// transactionsUntil returns the transactions dated at or before t.
+// Undated transactions cannot be placed in the past and are left out.
+func transactionsUntil(transactions []Transaction, t time.Time) []Transaction {
+	var out []Transaction
+	for _, tx := range transactions {
+		if d, err := parseTxDate(tx.Date); err == nil && !d.After(t) {
+			out = append(out, tx)
+		}
+	}
+	return out
+}
+
+// existingSnapshots maps Colombo dates to the history documents on them
+func existingSnapshots(ctx context.Context, uid string) (map[string][]*firestore.DocumentRef, error) {
+	iter := client.Collection("users").Doc(uid).Collection("history").Documents(ctx)
+	defer iter.Stop()
+
+	byDate := make(map[string][]*firestore.DocumentRef)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, _ := doc.Data()["date"].(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			continue
+		}
+		day := t.In(colombo).Format("2006-01-02")
+		byDate[day] = append(byDate[day], doc.Ref)
+	}
+	return byDate, nil
+}
+
+// backfillHistory regenerates a user's daily snapshots for every trading
+// day in [from, to] by replaying the transactions up to each day's close
+// through CalculatePortfolioState with the stored price history. Days before
+// the first transaction are left alone.
+func backfillHistory(ctx context.Context, uid, from, to, mode string, dryRun bool) (*BackfillResult, error) {
+	result := &BackfillResult{
+		UID: uid, From: from, To: to, Mode: mode, DryRun: dryRun,
+		Written: []string{}, Replaced: []string{}, Skipped: []string{}, Incomplete: []string{},
+	}
+
+	transactions, err := fetchTransactions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	settings := loadPortfolioSettings(ctx, uid)
+	manual, err := fetchManualPrices(ctx, uid)
+	if err != nil {
+		log.Printf("Error fetching manual prices for %s: %v", uid, err)
+	}
+
+	// Load each traded symbol's history once, including records before from
+	// so the first days can fall back to an earlier price
+	history := make(map[string][]PriceRecord)
+	for _, tx := range transactions {
+		if tx.Symbol == "" {
+			continue
+		}
+		if _, ok := history[tx.Symbol]; ok {
+			continue
+		}
+		records, err := fetchPriceHistory(ctx, tx.Symbol, "", to)
+		if err != nil {
+			return nil, err
+		}
+		history[tx.Symbol] = records
+	}
+
+	existing, err := existingSnapshots(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	historyRef := client.Collection("users").Doc(uid).Collection("history")
+	start, _ := time.ParseInLocation("2006-01-02", from, colombo)
+	end, _ := time.ParseInLocation("2006-01-02", to, colombo)
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		if ok, _ := calendar.IsTradingDay(t); !ok {
+			continue
+		}
+		day := t.Format("2006-01-02")
+		_, closeAt := calendar.Session(t)
+
+		txs := transactionsUntil(transactions, closeAt)
+		if len(txs) == 0 {
+			continue
+		}
+		refs := existing[day]
+		if len(refs) > 0 && mode == BackfillSkip {
+			result.Skipped = append(result.Skipped, day)
+			continue
+		}
+
+		quotes := applyManualPrices(historicalQuotes(history, day, closeAt), manual, day)
+		summary := CalculatePortfolioState(txs, quotes, settings)
+		record := snapshotRecord(summary, closeAt)
+		record["backfilled"] = true
+
+		if len(refs) > 0 {
+			result.Replaced = append(result.Replaced, day)
+		} else {
+			result.Written = append(result.Written, day)
+		}
+		if summary.ValuationIncomplete {
+			result.Incomplete = append(result.Incomplete, day)
+		}
+		if dryRun {
+			continue
+		}
+
+		// Each day is one batch, so a day is either replaced whole or left
+		// as it was. The day's own document is overwritten; any other
+		// snapshot on that day is removed.
+		dayRef := historyRef.Doc(historyDocID(closeAt))
+		batch := client.Batch()
+		for _, ref := range refs {
+			if ref.ID != dayRef.ID {
+				batch.Delete(ref)
+			}
+		}
+		batch.Set(dayRef, record)
+		if _, err := batch.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// handleBackfillHistory serves POST /admin/backfill-history?uid=&from=&to=&mode=
+//
+// mode is skip (default), which keeps dates that already have a snapshot,
+// or replace, which overwrites them. With ?dryRun=true nothing is written.
+func handleBackfillHistory(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+	mode := c.DefaultQuery("mode", BackfillSkip)
+	if mode != BackfillSkip && mode != BackfillReplace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be skip or replace"})
+		return
+	}
+	from, to, ok := parseDateRange(c, 30)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD with from <= to"})
+		return
+	}
+	fromDate, _ := time.Parse("2006-01-02", from)
+	toDate, _ := time.Parse("2006-01-02", to)
+	if toDate.Sub(fromDate) > maxBackfillDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Range too long; backfill at most 5 years at a time"})
+		return
+	}
+
+	result, err := backfillHistory(context.Background(), uid, from, to, mode, c.Query("dryRun") == "true")
+	if err != nil {
+		log.Printf("Error backfilling history for %s: %v", uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to backfill history"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Session phases reported by TradingCalendar.Phase
+const (
+	PhaseClosed    = "closed" // not a trading day
+	PhasePreOpen   = "pre-open"
+	PhaseOpen      = "open"
+	PhasePostClose = "post-close"
+)
+
+// TradingCalendar knows which Colombo dates the CSE trades on and when the
+// regular session runs. Weekends are always closed; everything else comes
+// from the holiday list, since Poya and bank holidays move every year.
+type TradingCalendar struct {
+	Location     *time.Location
+	Holidays     map[string]string // YYYY-MM-DD -> name
+	SessionOpen  time.Duration     // since local midnight
+	SessionClose time.Duration
+}
+
+// CSE regular session, 09:30-14:30 Asia/Colombo
+const (
+	defaultSessionOpen  = 9*time.Hour + 30*time.Minute
+	defaultSessionClose = 14*time.Hour + 30*time.Minute
+)
+
+// calendar is loaded in main once .env has been read
+var calendar *TradingCalendar
+
+// loadTradingCalendar builds the calendar from the environment:
+//
+//	CSE_HOLIDAYS_FILE   file with one "YYYY-MM-DD [name]" per line, # comments
+//	CSE_HOLIDAYS        comma-separated YYYY-MM-DD dates
+//	CSE_SESSION_OPEN    HH:MM, default 09:30
+//	CSE_SESSION_CLOSE   HH:MM, default 14:30
+//
+// Invalid settings are logged and ignored rather than stopping the server.
+func loadTradingCalendar() *TradingCalendar {
+	cal := &TradingCalendar{
+		Location:     colombo,
+		Holidays:     make(map[string]string),
+		SessionOpen:  defaultSessionOpen,
+		SessionClose: defaultSessionClose,
+	}
+
+	if path := os.Getenv("CSE_HOLIDAYS_FILE"); path != "" {
+		if err := cal.loadHolidayFile(path); err != nil {
+			log.Printf("Error loading holiday file %s: %v", path, err)
+		}
+	}
+	for _, d := range strings.Split(os.Getenv("CSE_HOLIDAYS"), ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			log.Printf("Ignoring CSE_HOLIDAYS entry %q: not YYYY-MM-DD", d)
+			continue
+		}
+		cal.Holidays[d] = "Holiday"
+	}
+
+	for env, dst := range map[string]*time.Duration{
+		"CSE_SESSION_OPEN":  &cal.SessionOpen,
+		"CSE_SESSION_CLOSE": &cal.SessionClose,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			log.Printf("Ignoring %s=%q: want HH:MM", env, v)
+			continue
+		}
+		*dst = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if cal.SessionClose <= cal.SessionOpen {
+		log.Printf("Session close is not after open; using 09:30-14:30")
+		cal.SessionOpen, cal.SessionClose = defaultSessionOpen, defaultSessionClose
+	}
+	return cal
+}
+
+func (cal *TradingCalendar) loadHolidayFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text == "" {
+			continue
+		}
+		date, name, _ := strings.Cut(text, " ")
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("line %d: %q is not YYYY-MM-DD", line, date)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "Holiday"
+		}
+		cal.Holidays[date] = name
+	}
+	return scanner.Err()
+}
+
+// midnight returns the start of t's Colombo date
+func (cal *TradingCalendar) midnight(t time.Time) time.Time {
+	y, m, d := t.In(cal.Location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, cal.Location)
+}
+
+// IsTradingDay reports whether the exchange trades on t's Colombo date and,
+// when it does not, why.
+func (cal *TradingCalendar) IsTradingDay(t time.Time) (bool, string) {
+	local := t.In(cal.Location)
+	switch local.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false, local.Weekday().String()
+	}
+	if name, ok := cal.Holidays[local.Format("2006-01-02")]; ok {
+		return false, name
+	}
+	return true, ""
+}
+
+// Session returns the open and close of the regular session on t's date
+func (cal *TradingCalendar) Session(t time.Time) (time.Time, time.Time) {
+	day := cal.midnight(t)
+	return day.Add(cal.SessionOpen), day.Add(cal.SessionClose)
+}
+
+// Phase places t relative to the trading session of its own date
+func (cal *TradingCalendar) Phase(t time.Time) string {
+	if ok, _ := cal.IsTradingDay(t); !ok {
+		return PhaseClosed
+	}
+	open, closeAt := cal.Session(t)
+	switch {
+	case t.Before(open):
+		return PhasePreOpen
+	case t.Before(closeAt):
+		return PhaseOpen
+	}
+	return PhasePostClose
+}
+
+// step walks from t's date by dir days until it reaches a trading day.
+// Bounded so a misconfigured holiday list cannot loop forever.
+func (cal *TradingCalendar) step(t time.Time, dir int) time.Time {
+	day := cal.midnight(t)
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, dir)
+		if ok, _ := cal.IsTradingDay(day); ok {
+			return day
+		}
+	}
+	return day
+}
+
+// PreviousTradingDay is the last trading date strictly before t's date
+func (cal *TradingCalendar) PreviousTradingDay(t time.Time) time.Time { return cal.step(t, -1) }
+
+// NextTradingDay is the first trading date strictly after t's date
+func (cal *TradingCalendar) NextTradingDay(t time.Time) time.Time { return cal.step(t, 1) }
+
+// LastClose is the most recent session close at or before t
+func (cal *TradingCalendar) LastClose(t time.Time) time.Time {
+	if cal.Phase(t) == PhasePostClose {
+		_, closeAt := cal.Session(t)
+		return closeAt
+	}
+	_, closeAt := cal.Session(cal.PreviousTradingDay(t))
+	return closeAt
+}
+
+// CalendarDay is the response body of GET /market/calendar
+type CalendarDay struct {
+	Date               string `json:"date"`
+	TradingDay         bool   `json:"tradingDay"`
+	Reason             string `json:"reason,omitempty"` // why the market is shut
+	SessionOpen        string `json:"sessionOpen,omitempty"`
+	SessionClose       string `json:"sessionClose,omitempty"`
+	PreviousTradingDay string `json:"previousTradingDay"`
+	NextTradingDay     string `json:"nextTradingDay"`
+
+	// Only set when date is today
+	Phase               string `json:"phase,omitempty"`
+	LastClose           string `json:"lastClose,omitempty"`
+	LatestUpdatedAt     string `json:"latestUpdatedAt,omitempty"`
+	LatestReflectsClose bool   `json:"latestReflectsClose"`
+}
+
+// describeDay fills in a CalendarDay for t's date; now decides the
+// today-only fields.
+func (cal *TradingCalendar) describeDay(t, now time.Time) CalendarDay {
+	day := CalendarDay{
+		Date:               t.In(cal.Location).Format("2006-01-02"),
+		PreviousTradingDay: cal.PreviousTradingDay(t).Format("2006-01-02"),
+		NextTradingDay:     cal.NextTradingDay(t).Format("2006-01-02"),
+	}
+	day.TradingDay, day.Reason = cal.IsTradingDay(t)
+	if day.TradingDay {
+		open, closeAt := cal.Session(t)
+		day.SessionOpen = open.Format(time.RFC3339)
+		day.SessionClose = closeAt.Format(time.RFC3339)
+	}
+	if day.Date == now.In(cal.Location).Format("2006-01-02") {
+		day.Phase = cal.Phase(now)
+		day.LastClose = cal.LastClose(now).Format(time.RFC3339)
+	}
+	return day
+}
+
+// latestReflectsClose reports whether market_data/latest was written after
+// the most recent session close and outside a session, i.e. holds closing
+// rather than intraday or stale prices.
+func (cal *TradingCalendar) latestReflectsClose(updatedAt string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return false
+	}
+	return !t.Before(cal.LastClose(now)) && cal.Phase(t) != PhaseOpen
+}
+
+// handleMarketCalendar serves GET /market/calendar?date=YYYY-MM-DD (default today)
+func handleMarketCalendar(c *gin.Context) {
+	now := time.Now()
+	t := now
+	if d := c.Query("date"); d != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", d, calendar.Location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		t = parsed
+	}
+
+	day := calendar.describeDay(t, now)
+	if day.Phase != "" {
+		snap, err := client.Collection(marketCollection).Doc(latestDoc).Get(context.Background())
+		if err == nil {
+			day.LatestUpdatedAt, _ = snap.Data()["updatedAt"].(string)
+			day.LatestReflectsClose = calendar.latestReflectsClose(day.LatestUpdatedAt, now)
+		}
+	}
+	c.JSON(http.StatusOK, day)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+const corporateActionsCollection = "corporate_actions"
+
+// corporateActionTypes are the events that legitimately move a price far
+// enough to trip the anomaly guard
+var corporateActionTypes = map[string]bool{
+	"split":         true,
+	"consolidation": true,
+	"bonus":         true,
+	"rights":        true,
+	"other":         true,
+}
+
+// CorporateAction registers an event that changes a security's price basis.
+// While one is in effect, large moves in that symbol are accepted rather
+// than quarantined.
+type CorporateAction struct {
+	ID            string `json:"id" firestore:"-"`
+	Symbol        string `json:"symbol" firestore:"symbol"`
+	Type          string `json:"type" firestore:"type"`
+	EffectiveDate string `json:"effectiveDate" firestore:"effectiveDate"` // ex-date, YYYY-MM-DD
+	Note          string `json:"note,omitempty" firestore:"note,omitempty"`
+	CreatedAt     string `json:"createdAt" firestore:"createdAt"`
+}
+
+// fetchCorporateActions returns registered actions, optionally for one
+// symbol, newest effective date first
+func fetchCorporateActions(ctx context.Context, symbol string) ([]CorporateAction, error) {
+	q := client.Collection(corporateActionsCollection).Query
+	if symbol != "" {
+		q = q.Where("symbol", "==", symbol)
+	}
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	actions := []CorporateAction{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var a CorporateAction
+		if err := doc.DataTo(&a); err != nil {
+			log.Printf("Error mapping corporate action %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		a.ID = doc.Ref.ID
+		actions = append(actions, a)
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].EffectiveDate > actions[j].EffectiveDate })
+	return actions, nil
+}
+
+// actionBetween reports whether any action for symbol takes effect in
+// [from, to], i.e. between the stored price and the incoming one
+func actionBetween(actions []CorporateAction, symbol, from, to string) bool {
+	for _, a := range actions {
+		if a.Symbol == symbol && a.EffectiveDate >= from && a.EffectiveDate <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// handleListCorporateActions serves GET /admin/corporate-actions?symbol=
+func handleListCorporateActions(c *gin.Context) {
+	symbol := strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+	actions, err := fetchCorporateActions(context.Background(), symbol)
+	if err != nil {
+		log.Printf("Error fetching corporate actions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch corporate actions"})
+		return
+	}
+	c.JSON(http.StatusOK, actions)
+}
+
+// handleAddCorporateAction serves POST /admin/corporate-actions
+func handleAddCorporateAction(c *gin.Context) {
+	var a CorporateAction
+	if err := c.BindJSON(&a); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	a.Symbol = strings.ToUpper(strings.TrimSpace(a.Symbol))
+	a.Type = strings.ToLower(strings.TrimSpace(a.Type))
+	if a.Symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
+		return
+	}
+	if !corporateActionTypes[a.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be split, consolidation, bonus, rights or other"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", a.EffectiveDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveDate must be YYYY-MM-DD"})
+		return
+	}
+	a.CreatedAt = time.Now().Format(time.RFC3339)
+
+	ref, _, err := client.Collection(corporateActionsCollection).Add(context.Background(), a)
+	if err != nil {
+		log.Printf("Error saving corporate action: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save corporate action"})
+		return
+	}
+	a.ID = ref.ID
+	c.JSON(http.StatusOK, a)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// Index levels (ASPI, S&P SL20, sector indices) are kept apart from
+// tradable symbols so they never appear in market_data, the symbol
+// directory or anyone's valuation. market_indices/latest maps index code to
+// its current level; index_history/{code}/daily/{YYYY-MM-DD} holds one
+// record per trading date, like market_history does for symbols.
+const (
+	indicesCollection      = "market_indices"
+	indexHistoryCollection = "index_history"
+)
+
+// IndexLevel is one index's value as sent by the task
+type IndexLevel struct {
+	Code      string  `json:"code" firestore:"code"`
+	Name      string  `json:"name,omitempty" firestore:"name,omitempty"`
+	Value     float64 `json:"value" firestore:"value"`
+	Change    float64 `json:"change" firestore:"change"`
+	ChangePct float64 `json:"changePct" firestore:"changePct"`
+	High      float64 `json:"high,omitempty" firestore:"high,omitempty"`
+	Low       float64 `json:"low,omitempty" firestore:"low,omitempty"`
+	Timestamp string  `json:"timestamp,omitempty" firestore:"timestamp,omitempty"` // RFC3339, from the source
+}
+
+// IndexRecord is one index's stored level for a trading date
+type IndexRecord struct {
+	Date            string  `json:"date" firestore:"date"`
+	Value           float64 `json:"value" firestore:"value"`
+	Change          float64 `json:"change" firestore:"change"`
+	ChangePct       float64 `json:"changePct" firestore:"changePct"`
+	High            float64 `json:"high,omitempty" firestore:"high,omitempty"`
+	Low             float64 `json:"low,omitempty" firestore:"low,omitempty"`
+	SourceTimestamp string  `json:"sourceTimestamp" firestore:"sourceTimestamp"`
+	RecordedAt      string  `json:"recordedAt" firestore:"recordedAt"`
+}
+
+// RejectedIndex explains why one index of an update was not stored
+type RejectedIndex struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// IndexUpdateResult is the response body of POST /market/indices
+type IndexUpdateResult struct {
+	Status   string          `json:"status"`
+	Stored   []string        `json:"stored"`
+	Rejected []RejectedIndex `json:"rejected"`
+}
+
+// validateIndexLevel normalises the code and checks the numbers
+func validateIndexLevel(level *IndexLevel) error {
+	level.Code = strings.ToUpper(strings.TrimSpace(level.Code))
+	if level.Code == "" || level.Code == "UPDATEDAT" || strings.Contains(level.Code, "/") {
+		return fmt.Errorf("code must be non-empty and must not contain /")
+	}
+	if level.Value <= 0 {
+		return fmt.Errorf("value must be positive")
+	}
+	if level.ChangePct <= -100 {
+		return fmt.Errorf("changePct %.2f is not a possible move", level.ChangePct)
+	}
+	if level.High < 0 || level.Low < 0 || (level.High > 0 && level.Low > 0 && level.High < level.Low) {
+		return fmt.Errorf("high and low must be non-negative with high >= low")
+	}
+	if level.Timestamp != "" {
+		if _, err := time.Parse(time.RFC3339, level.Timestamp); err != nil {
+			return fmt.Errorf("timestamp must be RFC3339")
+		}
+	}
+	return nil
+}
+
+// recordIndexHistory writes today's record for each level
+func recordIndexHistory(ctx context.Context, levels []IndexLevel, receivedAt time.Time) error {
+	bw := client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, level := range levels {
+		sourceTime := receivedAt
+		if t, err := time.Parse(time.RFC3339, level.Timestamp); err == nil {
+			sourceTime = t
+		}
+		record := IndexRecord{
+			Date:            sourceTime.In(colombo).Format("2006-01-02"),
+			Value:           level.Value,
+			Change:          level.Change,
+			ChangePct:       level.ChangePct,
+			High:            level.High,
+			Low:             level.Low,
+			SourceTimestamp: sourceTime.UTC().Format(time.RFC3339),
+			RecordedAt:      receivedAt.UTC().Format(time.RFC3339),
+		}
+		ref := client.Collection(indexHistoryCollection).Doc(level.Code).Collection(dailyCollection).Doc(record.Date)
+		job, err := bw.Set(ref, record)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleIndexUpdate serves POST /market/indices (called by the task). The
+// body is an array of levels; each listed index is merged into
+// market_indices/latest and every other index is kept.
+func handleIndexUpdate(c *gin.Context) {
+	var levels []IndexLevel
+	if err := c.BindJSON(&levels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	result := IndexUpdateResult{Stored: []string{}, Rejected: []RejectedIndex{}}
+	valid := make([]IndexLevel, 0, len(levels))
+	for _, level := range levels {
+		if err := validateIndexLevel(&level); err != nil {
+			result.Rejected = append(result.Rejected, RejectedIndex{Code: level.Code, Reason: err.Error()})
+			continue
+		}
+		valid = append(valid, level)
+		result.Stored = append(result.Stored, level.Code)
+	}
+	sort.Strings(result.Stored)
+
+	if len(valid) == 0 {
+		result.Status = "No valid indices in update"
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	doc := map[string]interface{}{"updatedAt": now.Format(time.RFC3339)}
+	paths := []firestore.FieldPath{{"updatedAt"}}
+	for _, level := range valid {
+		doc[level.Code] = level
+		paths = append(paths, firestore.FieldPath{level.Code})
+	}
+	if _, err := client.Collection(indicesCollection).Doc(latestDoc).Set(ctx, doc, firestore.Merge(paths...)); err != nil {
+		log.Printf("Error updating index levels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update index levels"})
+		return
+	}
+	if err := recordIndexHistory(ctx, valid, now); err != nil {
+		log.Printf("Error recording index history: %v", err)
+	}
+
+	result.Status = "Index levels updated"
+	c.JSON(http.StatusOK, result)
+}
+
+// handleListIndices serves GET /market/indices: the latest level of every index
+func handleListIndices(c *gin.Context) {
+	snap, err := client.Collection(indicesCollection).Doc(latestDoc).Get(context.Background())
+	if err != nil {
+		c.JSON(http.StatusOK, []IndexLevel{})
+		return
+	}
+
+	levels := []IndexLevel{}
+	for code, v := range snap.Data() {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue // updatedAt
+		}
+		level := IndexLevel{Code: code}
+		level.Name, _ = m["name"].(string)
+		level.Timestamp, _ = m["timestamp"].(string)
+		for key, dst := range map[string]*float64{
+			"value": &level.Value, "change": &level.Change, "changePct": &level.ChangePct,
+			"high": &level.High, "low": &level.Low,
+		} {
+			if d, ok := decimalFromValue(m[key]); ok {
+				*dst = d.Float64()
+			}
+		}
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Code < levels[j].Code })
+	c.JSON(http.StatusOK, levels)
+}
+
+// handleIndexHistory serves GET /market/indices/history?index=&from=&to=
+func handleIndexHistory(c *gin.Context) {
+	code := strings.ToUpper(c.Query("index"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing index parameter"})
+		return
+	}
+	from, to, ok := parseDateRange(c, 365)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD with from <= to"})
+		return
+	}
+
+	iter := client.Collection(indexHistoryCollection).Doc(code).Collection(dailyCollection).
+		Where("date", ">=", from).
+		Where("date", "<=", to).
+		OrderBy("date", firestore.Asc).
+		Documents(context.Background())
+	defer iter.Stop()
+
+	records := []IndexRecord{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("Error fetching index history: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch index history"})
+			return
+		}
+		var rec IndexRecord
+		if err := doc.DataTo(&rec); err != nil {
+			log.Printf("Error mapping index record %s: %v", doc.Ref.Path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	c.JSON(http.StatusOK, records)
+}
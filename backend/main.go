@@ -80,6 +80,9 @@ func main() {
 	debugListFiles()
 	initFirebase()
 	defer client.Close()
+	calendar = loadTradingCalendar()
+	serviceAuth = loadServiceAuth()
+	quoteCache = loadQuoteCache()
 
 	r := gin.Default()
 
@@ -106,74 +109,29 @@ func main() {
 
 		// 1. Fetch Transactions
 		// users/{uid}/transactions
-		iter := client.Collection("users").Doc(uid).Collection("transactions").Documents(ctx)
-		var transactions []Transaction
-		for {
-			doc, err := iter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				log.Printf("Error fetching transactions: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
-				return
-			}
-
-			var tx Transaction
-			if err := doc.DataTo(&tx); err != nil {
-                // If mapping fails, try manual map or logging
-                log.Printf("Error mapping tx: %v", err)
-				continue
-			}
-            // Ensure ID is set if not in data
-            if tx.ID == "" {
-                tx.ID = doc.Ref.ID
-            }
-			transactions = append(transactions, tx)
+		transactions, err := fetchTransactions(ctx, uid)
+		if err != nil {
+			log.Printf("Error fetching transactions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+			return
 		}
 
 		// 2. Fetch Market Data
-		// market_data/latest
-		dsnap, err := client.Collection("market_data").Doc("latest").Get(ctx)
-		marketPrices := make(map[string]float64)
-		if err == nil {
-			data := dsnap.Data()
-            for k, v := range data {
-                if k == "updatedAt" {
-                    continue
-                }
-                // Handle numbers. Firestore might return int64 or float64
-                switch val := v.(type) {
-                case float64:
-                    marketPrices[k] = val
-                case int64:
-                    marketPrices[k] = float64(val)
-                case int:
-                    marketPrices[k] = float64(val)
-                }
-            }
+		// market_data/latest, then the user's manual prices, then market_data/last_known
+		marketPrices, err := loadUserQuotes(ctx, uid)
+		if err != nil {
+			log.Printf("Error loading market prices: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+			return
 		}
 
 		// 3. Fetch Settings (Optional)
-        // users/{uid}/settings/general -> baseBankTransfer
-        // Or users/{uid} settings map?
-        // Following original TS logic which tried settings/general
-        var baseNetInvested *float64
-        settingsSnap, err := client.Collection("users").Doc(uid).Collection("settings").Doc("general").Get(ctx)
-        if err == nil {
-            if val, err := settingsSnap.DataAt("baseBankTransfer"); err == nil {
-                // Assert params
-                 switch v := val.(type) {
-                case float64:
-                    baseNetInvested = &v
-                case int64:
-                     f := float64(v)
-                     baseNetInvested = &f
-                }
-            }
-        }
+        // users/{uid}/settings/general -> baseBankTransfer, marginEnabled
+        settings := loadPortfolioSettings(ctx, uid)
 
-		summary := CalculatePortfolioState(transactions, marketPrices, baseNetInvested)
+		summary := CalculatePortfolioState(transactions, marketPrices, settings)
+		status := loadMarketStatus(ctx, time.Now())
+		summary.MarketData = &status
 
 		c.JSON(http.StatusOK, summary)
 	})
@@ -201,6 +159,24 @@ func main() {
         c.JSON(http.StatusOK, symbols)
     })
 
+    // Symbol directory: fuzzy search on symbol and company name, and manual curation
+    r.GET("/market/symbols/search", handleSymbolSearch)
+    r.PUT("/market/symbols/:symbol", requireServiceAuth, handleUpdateSymbol)
+
+    // Daily price series for one symbol
+    r.GET("/market/history", handlePriceHistory)
+
+    // Trading days, session hours and whether latest holds closing prices
+    r.GET("/market/calendar", handleMarketCalendar)
+
+    // Index levels (ASPI, S&P SL20, sectors), kept apart from tradable symbols
+    r.POST("/market/indices", requireServiceAuth, handleIndexUpdate)
+    r.GET("/market/indices", handleListIndices)
+    r.GET("/market/indices/history", handleIndexHistory)
+
+    // Age, source and staleness of market_data/latest
+    r.GET("/market/status", handleMarketStatus)
+
     r.GET("/portfolio/transactions", func(c *gin.Context) {
         uid := c.Query("uid")
         if uid == "" {
@@ -243,118 +219,11 @@ func main() {
     })
 
     // Update Market Data (Called by Task)
-    r.POST("/market/update", func(c *gin.Context) {
-        var marketData map[string]interface{}
-        if err := c.BindJSON(&marketData); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
-            return
-        }
-        
-        // Ensure updatedAt is set
-        marketData["updatedAt"] = time.Now().Format(time.RFC3339)
-
-        ctx := context.Background()
-        _, err := client.Collection("market_data").Doc("latest").Set(ctx, marketData)
-        if err != nil {
-            log.Printf("Error updating market data: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update market data"})
-            return
-        }
-
-        c.JSON(http.StatusOK, gin.H{"status": "Market data updated"})
-    })
+    // Merges by default; ?mode=replace swaps the whole document
+    r.POST("/market/update", requireServiceAuth, handleMarketUpdate)
 
     // Trigger Snapshot (Called by Task)
-    r.POST("/portfolio/snapshot", func(c *gin.Context) {
-        uid := c.Query("uid")
-        if uid == "" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
-            return
-        }
-
-        ctx := context.Background()
-
-        // 1. Fetch Transactions
-        iter := client.Collection("users").Doc(uid).Collection("transactions").Documents(ctx)
-        var transactions []Transaction
-        for {
-            doc, err := iter.Next()
-            if err == iterator.Done {
-                break
-            }
-            if err != nil {
-                log.Printf("Error fetching transactions: %v", err)
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
-                return
-            }
-
-            var tx Transaction
-            if err := doc.DataTo(&tx); err != nil {
-                continue
-            }
-            if tx.ID == "" {
-                tx.ID = doc.Ref.ID
-            }
-            transactions = append(transactions, tx)
-        }
-
-        // 2. Fetch Market Data
-        dsnap, err := client.Collection("market_data").Doc("latest").Get(ctx)
-        marketPrices := make(map[string]float64)
-        if err == nil {
-            data := dsnap.Data()
-            for k, v := range data {
-                if k == "updatedAt" {
-                    continue
-                }
-                switch val := v.(type) {
-                case float64:
-                    marketPrices[k] = val
-                case int64:
-                    marketPrices[k] = float64(val)
-                case int:
-                    marketPrices[k] = float64(val)
-                }
-            }
-        }
-
-        // 3. Fetch Settings
-        var baseNetInvested *float64
-        settingsSnap, err := client.Collection("users").Doc(uid).Collection("settings").Doc("general").Get(ctx)
-        if err == nil {
-            if val, err := settingsSnap.DataAt("baseBankTransfer"); err == nil {
-                 switch v := val.(type) {
-                case float64:
-                    baseNetInvested = &v
-                case int64:
-                     f := float64(v)
-                     baseNetInvested = &f
-                }
-            }
-        }
-
-        // 4. Calculate State
-        summary := CalculatePortfolioState(transactions, marketPrices, baseNetInvested)
-
-        // 5. Save Snapshot
-        snapshot := map[string]interface{}{
-            "date":              time.Now().Format(time.RFC3339),
-            "netWorth":          summary.NetWorth,
-            "netInvested":       summary.NetInvested,
-            "cashOnHand":        summary.CashOnHand,
-            "totalGain":         summary.TotalLifecycleGain,
-            "holdingsCount":     len(summary.Holdings),
-        }
-
-        _, _, err = client.Collection("users").Doc(uid).Collection("history").Add(ctx, snapshot)
-        if err != nil {
-            log.Printf("Error saving snapshot: %v", err)
-             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snapshot"})
-             return
-        }
-
-        c.JSON(http.StatusOK, gin.H{"status": "Snapshot saved", "data": snapshot})
-    })
+    r.POST("/portfolio/snapshot", requireServiceAuth, handleSnapshot)
 
     // Get History for Graphs
     r.GET("/portfolio/history", func(c *gin.Context) {
@@ -389,8 +258,34 @@ func main() {
         c.JSON(http.StatusOK, history)
     })
 
+    // Manual prices for suspended, delisted or unlisted holdings
+    r.GET("/portfolio/manual-prices", handleListManualPrices)
+    r.POST("/portfolio/manual-prices", handleAddManualPrice)
+    r.DELETE("/portfolio/manual-prices/:id", handleDeleteManualPrice)
+
+    // Tax-year capital gains and dividend income (JSON or ?format=csv)
+    r.GET("/portfolio/tax-report", handleTaxReport)
+
+    // Admin routes, like the task's write routes above, require a signed
+    // request unless SERVICE_AUTH_DISABLED is set (see serviceauth.go)
+
+    // Users with transactions, for the task's nightly snapshots
+    r.GET("/admin/users", requireServiceAuth, handleListUsers)
+
+    // Anomaly guard review and the corporate actions that exempt a symbol
+    r.GET("/admin/market/quarantine", requireServiceAuth, handleListQuarantine)
+    r.POST("/admin/market/quarantine/:symbol/accept", requireServiceAuth, handleResolveQuarantine(true))
+    r.POST("/admin/market/quarantine/:symbol/reject", requireServiceAuth, handleResolveQuarantine(false))
+    r.GET("/admin/corporate-actions", requireServiceAuth, handleListCorporateActions)
+    r.POST("/admin/corporate-actions", requireServiceAuth, handleAddCorporateAction)
+    r.POST("/admin/backfill-history", requireServiceAuth, handleBackfillHistory)
+
+    // Outcome of each task run, recorded by the task itself
+    r.POST("/admin/task-runs", requireServiceAuth, handleRecordTaskRun)
+    r.GET("/admin/task-runs", requireServiceAuth, handleListTaskRuns)
+
     // Admin: Seed History (Dummy Data)
-    r.POST("/admin/seed-history", func(c *gin.Context) {
+    r.POST("/admin/seed-history", requireServiceAuth, func(c *gin.Context) {
         uid := c.Query("uid")
         if uid == "" {
            uid = "demo-user"
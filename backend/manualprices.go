@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// ManualPrice is a user-entered price for a security no feed covers
+// (suspended, delisted or unlisted). Stored at users/{uid}/manual_prices,
+// with the price kept as a plain number like the market prices.
+type ManualPrice struct {
+	ID            string  `json:"id" firestore:"-"`
+	Symbol        string  `json:"symbol" firestore:"symbol"`
+	Price         Decimal `json:"price" firestore:"-"`
+	EffectiveDate string  `json:"effectiveDate" firestore:"effectiveDate"` // YYYY-MM-DD
+	Note          string  `json:"note,omitempty" firestore:"note,omitempty"`
+	CreatedAt     string  `json:"createdAt" firestore:"createdAt"`
+}
+
+// stored is the Firestore document for the entry
+func (mp ManualPrice) stored() map[string]interface{} {
+	doc := map[string]interface{}{
+		"symbol":        mp.Symbol,
+		"price":         mp.Price.Float64(),
+		"effectiveDate": mp.EffectiveDate,
+		"createdAt":     mp.CreatedAt,
+	}
+	if mp.Note != "" {
+		doc["note"] = mp.Note
+	}
+	return doc
+}
+
+func manualPricesRef(uid string) *firestore.CollectionRef {
+	return client.Collection("users").Doc(uid).Collection("manual_prices")
+}
+
+// fetchManualPrices returns a user's manual price entries, newest effective date first
+func fetchManualPrices(ctx context.Context, uid string) ([]ManualPrice, error) {
+	iter := manualPricesRef(uid).Documents(ctx)
+	defer iter.Stop()
+
+	prices := []ManualPrice{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var mp ManualPrice
+		if err := doc.DataTo(&mp); err != nil {
+			log.Printf("Error mapping manual price %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		price, ok := decimalFromValue(doc.Data()["price"])
+		if !ok {
+			log.Printf("Error mapping manual price %s: price is not a number", doc.Ref.ID)
+			continue
+		}
+		mp.ID = doc.Ref.ID
+		mp.Price = price
+		prices = append(prices, mp)
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].EffectiveDate != prices[j].EffectiveDate {
+			return prices[i].EffectiveDate > prices[j].EffectiveDate
+		}
+		return prices[i].CreatedAt > prices[j].CreatedAt
+	})
+	return prices, nil
+}
+
+// applyManualPrices fills in symbols that have no live price with the
+// user's most recent manual entry effective on or before asOf. Live data
+// always wins; a manual entry beats a last-known fallback because the user
+// set it deliberately for a security the feed no longer covers.
+func applyManualPrices(quotes map[string]PriceQuote, manual []ManualPrice, asOf string) map[string]PriceQuote {
+	merged := make(map[string]PriceQuote, len(quotes))
+	for k, v := range quotes {
+		merged[k] = v
+	}
+
+	// manual is newest first, so the first applicable entry per symbol wins
+	decided := make(map[string]bool)
+	for _, mp := range manual {
+		if decided[mp.Symbol] || mp.EffectiveDate > asOf {
+			continue
+		}
+		decided[mp.Symbol] = true
+		if q, ok := merged[mp.Symbol]; ok && q.Status == PriceLive {
+			continue
+		}
+		merged[mp.Symbol] = PriceQuote{
+			Price:  mp.Price,
+			Status: PriceManual,
+			AsOf:   mp.EffectiveDate,
+		}
+	}
+	return merged
+}
+
+// loadUserQuotes is the price map a user's portfolio is valued with, read
+// through the quote cache. It fails only if the market prices cannot be read.
+func loadUserQuotes(ctx context.Context, uid string) (map[string]PriceQuote, error) {
+	quotes, err := quoteCache.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withManualPrices(ctx, uid, quotes), nil
+}
+
+// withManualPrices applies a user's manual prices to the market quotes. If
+// they cannot be read the market quotes are used alone.
+func withManualPrices(ctx context.Context, uid string, quotes map[string]PriceQuote) map[string]PriceQuote {
+	manual, err := fetchManualPrices(ctx, uid)
+	if err != nil {
+		log.Printf("Error fetching manual prices for %s: %v", uid, err)
+		return quotes
+	}
+	return applyManualPrices(quotes, manual, time.Now().In(colombo).Format("2006-01-02"))
+}
+
+// handleListManualPrices serves GET /portfolio/manual-prices?uid=
+func handleListManualPrices(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+	prices, err := fetchManualPrices(context.Background(), uid)
+	if err != nil {
+		log.Printf("Error fetching manual prices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch manual prices"})
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
+
+// handleAddManualPrice serves POST /portfolio/manual-prices?uid=
+func handleAddManualPrice(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+
+	var mp ManualPrice
+	if err := c.BindJSON(&mp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	mp.Symbol = strings.ToUpper(strings.TrimSpace(mp.Symbol))
+	if mp.Symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
+		return
+	}
+	if mp.Price <= 0 || mp.Price > maxMarketPrice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be positive and at most " + maxMarketPrice.String()})
+		return
+	}
+	if mp.EffectiveDate == "" {
+		mp.EffectiveDate = time.Now().In(colombo).Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", mp.EffectiveDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveDate must be YYYY-MM-DD"})
+		return
+	}
+	mp.CreatedAt = time.Now().Format(time.RFC3339)
+
+	ref, _, err := manualPricesRef(uid).Add(context.Background(), mp.stored())
+	if err != nil {
+		log.Printf("Error saving manual price: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save manual price"})
+		return
+	}
+	mp.ID = ref.ID
+	c.JSON(http.StatusOK, mp)
+}
+
+// handleDeleteManualPrice serves DELETE /portfolio/manual-prices/:id?uid=
+func handleDeleteManualPrice(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+	_, err := manualPricesRef(uid).Doc(c.Param("id")).Delete(context.Background())
+	if err != nil {
+		log.Printf("Error deleting manual price: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete manual price"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Manual price deleted"})
+}
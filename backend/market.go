@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// market_data documents. latest is the flat symbol -> price map written by
+// the task; last_known keeps the most recent price ever seen per symbol so a
+// symbol dropping out of a scrape does not value the holding at zero.
+const (
+	marketCollection = "market_data"
+	latestDoc        = "latest"
+	lastKnownDoc     = "last_known"
+)
+
+// MarketEntry is one symbol's value in market_data/latest. Documents written
+// before previous close was captured store a bare number instead of a map.
+type MarketEntry struct {
+	Price         Decimal
+	PreviousClose Decimal // zero when unknown
+	ChangePct     Decimal
+
+	// Daily bar fields; zero when the source does not report them
+	Open     Decimal
+	High     Decimal
+	Low      Decimal
+	Volume   Decimal // shares traded
+	Trades   Decimal // number of trades
+	Turnover Decimal
+}
+
+// barFields lists the optional per-day fields an entry may carry, in the
+// order they are validated and stored.
+var barFields = []string{"open", "high", "low", "volume", "trades", "turnover"}
+
+func (e *MarketEntry) barField(name string) *Decimal {
+	switch name {
+	case "open":
+		return &e.Open
+	case "high":
+		return &e.High
+	case "low":
+		return &e.Low
+	case "volume":
+		return &e.Volume
+	case "trades":
+		return &e.Trades
+	case "turnover":
+		return &e.Turnover
+	}
+	return nil
+}
+
+func parseMarketEntry(v interface{}) (MarketEntry, bool) {
+	if price, ok := decimalFromValue(v); ok {
+		return MarketEntry{Price: price}, true
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return MarketEntry{}, false
+	}
+	price, ok := decimalFromValue(m["price"])
+	if !ok {
+		return MarketEntry{}, false
+	}
+	entry := MarketEntry{Price: price}
+	entry.PreviousClose, _ = decimalFromValue(m["previousClose"])
+	entry.ChangePct, _ = decimalFromValue(m["changePct"])
+	for _, f := range barFields {
+		*entry.barField(f), _ = decimalFromValue(m[f])
+	}
+	return entry, true
+}
+
+// loadMarketQuotes returns the price for every symbol we know about. Live
+// prices come from market_data/latest; symbols absent there fall back to
+// their last stored price. A document that does not exist yet is empty; any
+// other read error fails the load, since a portfolio valued from half the
+// prices must not be saved as history.
+func loadMarketQuotes(ctx context.Context) (map[string]PriceQuote, error) {
+	quotes := make(map[string]PriceQuote)
+
+	snap, err := client.Collection(marketCollection).Doc(latestDoc).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, fmt.Errorf("reading %s/%s: %w", marketCollection, latestDoc, err)
+	}
+	if err == nil {
+		data := snap.Data()
+		asOf, _ := data["updatedAt"].(string)
+		for k, v := range data {
+			if k == "updatedAt" {
+				continue
+			}
+			// A zero price is no price; let last_known or a manual price stand in
+			if entry, ok := parseMarketEntry(v); ok && entry.Price > 0 {
+				quotes[k] = PriceQuote{Price: entry.Price, PreviousClose: entry.PreviousClose, Status: PriceLive, AsOf: asOf}
+			}
+		}
+	}
+
+	snap, err = client.Collection(marketCollection).Doc(lastKnownDoc).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, fmt.Errorf("reading %s/%s: %w", marketCollection, lastKnownDoc, err)
+	}
+	if err == nil {
+		for k, v := range snap.Data() {
+			if _, ok := quotes[k]; ok {
+				continue
+			}
+			entry, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if price, ok := decimalFromValue(entry["price"]); ok {
+				asOf, _ := entry["asOf"].(string)
+				quotes[k] = PriceQuote{Price: price, Status: PriceLastKnown, AsOf: asOf}
+			}
+		}
+	}
+
+	return quotes, nil
+}
+
+// recordLastKnownPrices merges every numeric price in an update into
+// market_data/last_known, leaving symbols not in the update untouched.
+func recordLastKnownPrices(ctx context.Context, marketData map[string]interface{}, asOf time.Time) error {
+	entries := make(map[string]interface{})
+	for k, v := range marketData {
+		if k == "updatedAt" {
+			continue
+		}
+		if entry, ok := parseMarketEntry(v); ok && entry.Price > 0 {
+			entries[k] = map[string]interface{}{
+				"price": entry.Price.Float64(),
+				"asOf":  asOf.Format(time.RFC3339),
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := client.Collection(marketCollection).Doc(lastKnownDoc).Set(ctx, entries, firestore.MergeAll)
+	return err
+}
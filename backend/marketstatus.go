@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusDoc is market_data/status, rewritten on every update. It lives
+// beside latest rather than inside it so latest stays a symbol map.
+const statusDoc = "status"
+
+// defaultStaleGrace is how long after a session close the closing prices
+// may take to arrive before the data counts as stale. MARKET_STALE_AFTER
+// (a Go duration such as "3h") overrides it.
+const defaultStaleGrace = 2 * time.Hour
+
+// MarketStatus describes the market data currently being served
+type MarketStatus struct {
+	UpdatedAt   string `json:"updatedAt,omitempty" firestore:"updatedAt"`
+	Source      string `json:"source,omitempty" firestore:"source,omitempty"`
+	Mode        string `json:"mode,omitempty" firestore:"mode,omitempty"`
+	SymbolCount int    `json:"symbolCount" firestore:"symbolCount"`
+
+	// Derived at read time
+	AgeSeconds int64  `json:"ageSeconds" firestore:"-"`
+	LastClose  string `json:"lastClose" firestore:"-"`
+	StaleAfter string `json:"staleAfter" firestore:"-"` // when missing the last close becomes stale
+	Stale      bool   `json:"stale" firestore:"-"`
+}
+
+func staleGrace() time.Duration {
+	if v := os.Getenv("MARKET_STALE_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d >= 0 {
+			return d
+		}
+		log.Printf("Ignoring MARKET_STALE_AFTER=%q: want a non-negative duration", v)
+	}
+	return defaultStaleGrace
+}
+
+// recordMarketStatus overwrites market_data/status after an update
+func recordMarketStatus(ctx context.Context, status MarketStatus) error {
+	_, err := client.Collection(marketCollection).Doc(statusDoc).Set(ctx, status)
+	return err
+}
+
+// loadMarketStatus reads market_data/status and works out how fresh it is.
+// Data written before the status document existed falls back to the
+// updatedAt stamp inside latest.
+func loadMarketStatus(ctx context.Context, now time.Time) MarketStatus {
+	var status MarketStatus
+	if snap, err := client.Collection(marketCollection).Doc(statusDoc).Get(ctx); err == nil {
+		if err := snap.DataTo(&status); err != nil {
+			log.Printf("Error mapping market status: %v", err)
+		}
+	}
+	if status.UpdatedAt == "" {
+		if snap, err := client.Collection(marketCollection).Doc(latestDoc).Get(ctx); err == nil {
+			data := snap.Data()
+			status.UpdatedAt, _ = data["updatedAt"].(string)
+			for k := range data {
+				if k != "updatedAt" {
+					status.SymbolCount++
+				}
+			}
+		}
+	}
+	status.assess(now, staleGrace())
+	return status
+}
+
+// assess fills in the derived fields. Prices are stale once the most recent
+// session close is more than grace old and no update has arrived since it,
+// so a weekend or holiday never counts against the data.
+func (s *MarketStatus) assess(now time.Time, grace time.Duration) {
+	lastClose := calendar.LastClose(now)
+	staleAfter := lastClose.Add(grace)
+	s.LastClose = lastClose.Format(time.RFC3339)
+	s.StaleAfter = staleAfter.Format(time.RFC3339)
+
+	updatedAt, err := time.Parse(time.RFC3339, s.UpdatedAt)
+	if err != nil {
+		// No market data at all is as stale as it gets
+		s.AgeSeconds = 0
+		s.Stale = true
+		return
+	}
+	s.AgeSeconds = int64(now.Sub(updatedAt).Seconds())
+	s.Stale = updatedAt.Before(lastClose) && now.After(staleAfter)
+}
+
+// handleMarketStatus serves GET /market/status
+func handleMarketStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, loadMarketStatus(context.Background(), time.Now()))
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+// maxMarketPrice is a sanity ceiling; no CSE security trades anywhere near it
+var maxMarketPrice = NewDecimalFromInt(1_000_000)
+
+// maxSymbolLength bounds a symbol, which becomes a document id in
+// market_history, symbols and the quarantine
+const maxSymbolLength = 64
+
+// RejectedSymbol explains why one entry of an update was not stored
+type RejectedSymbol struct {
+	Symbol string `json:"symbol"`
+	Reason string `json:"reason"`
+}
+
+// MarketUpdateResult is the response body of POST /market/update
+type MarketUpdateResult struct {
+	Status    string           `json:"status"`
+	Mode      string           `json:"mode"`
+	Added     []string         `json:"added"`
+	Changed   []string         `json:"changed"`
+	Unchanged []string         `json:"unchanged"`
+	Removed   []string         `json:"removed,omitempty"`
+	Rejected  []RejectedSymbol `json:"rejected"`
+
+	// Quarantined prices moved too far to store unreviewed
+	Quarantined []QuarantinedPrice `json:"quarantined"`
+
+	// DryRun is set when nothing was written; Changes then lists the old
+	// and new price of every changed symbol.
+	DryRun  bool          `json:"dryRun,omitempty"`
+	Changes []PriceChange `json:"changes,omitempty"`
+}
+
+// PriceChange is one symbol's stored and incoming price
+type PriceChange struct {
+	Symbol        string  `json:"symbol"`
+	PreviousPrice float64 `json:"previousPrice"`
+	Price         float64 `json:"price"`
+}
+
+// validateMarketEntry checks one symbol of an update and returns the
+// canonical map that gets stored for it.
+func validateMarketEntry(symbol string, v interface{}) (map[string]interface{}, MarketEntry, error) {
+	if symbol == "" || symbol == "updatedAt" {
+		return nil, MarketEntry{}, fmt.Errorf("reserved or empty symbol")
+	}
+	// The symbol is also a document id, and Doc returns nil for one with a /
+	if strings.Contains(symbol, "/") || symbol == "." || symbol == ".." || len(symbol) > maxSymbolLength {
+		return nil, MarketEntry{}, fmt.Errorf("symbol must not contain / and must be at most %d bytes", maxSymbolLength)
+	}
+
+	var raw map[string]interface{}
+	switch val := v.(type) {
+	case float64:
+		raw = map[string]interface{}{"price": val}
+	case map[string]interface{}:
+		raw = val
+	default:
+		return nil, MarketEntry{}, fmt.Errorf("value must be a number or an object with a numeric price")
+	}
+
+	entry, ok := parseMarketEntry(raw)
+	if !ok {
+		return nil, MarketEntry{}, fmt.Errorf("price must be numeric")
+	}
+	if entry.Price <= 0 || entry.Price > maxMarketPrice {
+		return nil, MarketEntry{}, fmt.Errorf("price %s outside (0, %s]", entry.Price, maxMarketPrice)
+	}
+	if pc, present := raw["previousClose"]; present {
+		if _, ok := decimalFromValue(pc); !ok {
+			return nil, MarketEntry{}, fmt.Errorf("previousClose must be numeric")
+		}
+		if entry.PreviousClose < 0 || entry.PreviousClose > maxMarketPrice {
+			return nil, MarketEntry{}, fmt.Errorf("previousClose %s outside [0, %s]", entry.PreviousClose, maxMarketPrice)
+		}
+	}
+	if cp, present := raw["changePct"]; present {
+		if _, ok := decimalFromValue(cp); !ok {
+			return nil, MarketEntry{}, fmt.Errorf("changePct must be numeric")
+		}
+		if entry.ChangePct <= NewDecimalFromInt(-100) {
+			return nil, MarketEntry{}, fmt.Errorf("changePct %s is not a possible move", entry.ChangePct)
+		}
+	}
+
+	for _, f := range barFields {
+		v, present := raw[f]
+		if !present {
+			continue
+		}
+		if _, ok := decimalFromValue(v); !ok {
+			return nil, MarketEntry{}, fmt.Errorf("%s must be numeric", f)
+		}
+		if *entry.barField(f) < 0 {
+			return nil, MarketEntry{}, fmt.Errorf("%s must not be negative", f)
+		}
+	}
+	if entry.High > 0 && entry.Low > 0 && entry.High < entry.Low {
+		return nil, MarketEntry{}, fmt.Errorf("high %s is below low %s", entry.High, entry.Low)
+	}
+	if entry.High > maxMarketPrice {
+		return nil, MarketEntry{}, fmt.Errorf("high %s above %s", entry.High, maxMarketPrice)
+	}
+
+	stored := map[string]interface{}{"price": entry.Price.Float64()}
+	for _, f := range barFields {
+		if _, present := raw[f]; present {
+			stored[f] = entry.barField(f).Float64()
+		}
+	}
+	if entry.PreviousClose > 0 {
+		stored["previousClose"] = entry.PreviousClose.Float64()
+	}
+	if _, present := raw["changePct"]; present {
+		stored["changePct"] = entry.ChangePct.Float64()
+	}
+	if name, ok := raw["name"].(string); ok && name != "" {
+		stored["name"] = name
+	}
+	if ts, ok := raw["timestamp"].(string); ok {
+		if _, err := time.Parse(time.RFC3339, ts); err != nil {
+			return nil, MarketEntry{}, fmt.Errorf("timestamp must be RFC3339")
+		}
+		stored["timestamp"] = ts
+	}
+	return stored, entry, nil
+}
+
+// handleMarketUpdate serves POST /market/update (called by the task).
+//
+// By default the update is merged: only the symbols in the body are
+// written and every other symbol in market_data/latest is kept. ?mode=replace
+// makes the body the complete new document, except that a symbol whose new
+// entry is rejected or held keeps its stored value. Invalid entries are
+// rejected individually and never reach storage. Prices that moved further than
+// MARKET_MAX_MOVE_PCT against the stored value, with no corporate action to
+// explain it, are quarantined for admin review instead of stored. ?source=
+// names the feed for GET /market/status. ?dryRun=true runs every check and
+// reports what would change without writing anything.
+func handleMarketUpdate(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be merge or replace"})
+		return
+	}
+
+	var marketData map[string]interface{}
+	if err := c.BindJSON(&marketData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	result := MarketUpdateResult{
+		Mode:        mode,
+		Added:       []string{},
+		Changed:     []string{},
+		Unchanged:   []string{},
+		Rejected:    []RejectedSymbol{},
+		Quarantined: []QuarantinedPrice{},
+		DryRun:      dryRun,
+	}
+
+	accepted := make(map[string]interface{})
+	entries := make(map[string]MarketEntry)
+	for symbol, v := range marketData {
+		if symbol == "updatedAt" {
+			continue // set by the server
+		}
+		stored, entry, err := validateMarketEntry(symbol, v)
+		if err != nil {
+			result.Rejected = append(result.Rejected, RejectedSymbol{Symbol: symbol, Reason: err.Error()})
+			continue
+		}
+		accepted[symbol] = stored
+		entries[symbol] = entry
+	}
+	sort.Slice(result.Rejected, func(i, j int) bool { return result.Rejected[i].Symbol < result.Rejected[j].Symbol })
+
+	if len(accepted) == 0 {
+		result.Status = "No valid symbols in update"
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	ctx := context.Background()
+	latestRef := client.Collection(marketCollection).Doc(latestDoc)
+
+	now := time.Now()
+	currentRaw := make(map[string]interface{})
+	current := make(map[string]MarketEntry)
+	prevDates := make(map[string]string)
+	if snap, err := latestRef.Get(ctx); err == nil {
+		currentRaw = snap.Data()
+		docUpdatedAt, _ := currentRaw["updatedAt"].(string)
+		for k, v := range currentRaw {
+			if k == "updatedAt" {
+				continue
+			}
+			if e, ok := parseMarketEntry(v); ok {
+				current[k] = e
+				prevDates[k] = storedDate(v, docUpdatedAt)
+			}
+		}
+	}
+
+	// Anomaly guard
+	actions, err := fetchCorporateActions(ctx, "")
+	if err != nil {
+		log.Printf("Error fetching corporate actions: %v", err)
+	}
+	today := now.In(colombo).Format("2006-01-02")
+	held := screenMarketMoves(entries, current, prevDates, actions, maxMovePct(), today)
+	for symbol, move := range held {
+		result.Quarantined = append(result.Quarantined, QuarantinedPrice{
+			Symbol:        symbol,
+			Entry:         accepted[symbol].(map[string]interface{}),
+			Price:         entries[symbol].Price.Float64(),
+			PreviousPrice: current[symbol].Price.Float64(),
+			MovePct:       move.Round(2).Float64(),
+			Source:        c.Query("source"),
+			ReceivedAt:    now.Format(time.RFC3339),
+			Status:        QuarantinePending,
+		})
+	}
+	sort.Slice(result.Quarantined, func(i, j int) bool { return result.Quarantined[i].Symbol < result.Quarantined[j].Symbol })
+	// A held price that is not in the quarantine would be lost, so nothing
+	// is stored unless the quarantine write succeeds; the task retries
+	if !dryRun {
+		if err := quarantinePrices(ctx, result.Quarantined); err != nil {
+			log.Printf("Error quarantining prices: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quarantine held prices; nothing was stored"})
+			return
+		}
+	}
+	for symbol := range held {
+		delete(accepted, symbol)
+		delete(entries, symbol)
+	}
+	if len(accepted) == 0 {
+		result.Status = "All prices held for review"
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	for symbol, entry := range entries {
+		prev, existed := current[symbol]
+		switch {
+		case !existed:
+			result.Added = append(result.Added, symbol)
+		case prev.Price != entry.Price || prev.PreviousClose != entry.PreviousClose:
+			result.Changed = append(result.Changed, symbol)
+		default:
+			result.Unchanged = append(result.Unchanged, symbol)
+		}
+	}
+	// A replace keeps the stored value of a symbol whose new price was held
+	// or rejected; one bad row from the source must not wipe a good price
+	kept := make(map[string]bool)
+	if mode == "replace" {
+		for symbol := range held {
+			kept[symbol] = true
+		}
+		for _, r := range result.Rejected {
+			kept[r.Symbol] = true
+		}
+		for symbol := range current {
+			if _, ok := accepted[symbol]; !ok && !kept[symbol] {
+				result.Removed = append(result.Removed, symbol)
+			}
+		}
+	}
+	for _, list := range [][]string{result.Added, result.Changed, result.Unchanged, result.Removed} {
+		sort.Strings(list)
+	}
+
+	if dryRun {
+		for _, symbol := range result.Changed {
+			result.Changes = append(result.Changes, PriceChange{
+				Symbol:        symbol,
+				PreviousPrice: current[symbol].Price.Float64(),
+				Price:         entries[symbol].Price.Float64(),
+			})
+		}
+		result.Status = "Dry run: nothing stored"
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	doc := make(map[string]interface{}, len(accepted)+1)
+	for k, v := range accepted {
+		doc[k] = v
+	}
+	doc["updatedAt"] = now.Format(time.RFC3339)
+
+	if mode == "replace" {
+		for symbol := range kept {
+			if v, ok := currentRaw[symbol]; ok {
+				doc[symbol] = v
+			}
+		}
+		_, err = latestRef.Set(ctx, doc)
+	} else {
+		// Symbols contain dots, so merge on explicit single-segment paths;
+		// each listed symbol is replaced whole rather than deep-merged.
+		paths := make([]firestore.FieldPath, 0, len(doc))
+		for k := range doc {
+			paths = append(paths, firestore.FieldPath{k})
+		}
+		_, err = latestRef.Set(ctx, doc, firestore.Merge(paths...))
+	}
+	if err != nil {
+		log.Printf("Error updating market data: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update market data"})
+		return
+	}
+
+	symbolCount := len(doc) - 1
+	if mode == "merge" {
+		for symbol := range current {
+			if _, ok := accepted[symbol]; !ok {
+				symbolCount++
+			}
+		}
+	}
+	status := MarketStatus{
+		UpdatedAt:   doc["updatedAt"].(string),
+		Source:      c.Query("source"),
+		Mode:        mode,
+		SymbolCount: symbolCount,
+	}
+	if err := recordMarketStatus(ctx, status); err != nil {
+		log.Printf("Error recording market status: %v", err)
+	}
+
+	recordMarketSideStores(ctx, accepted, now)
+	if err := supersedeQuarantine(ctx, accepted, now); err != nil {
+		log.Printf("Error superseding quarantined prices: %v", err)
+	}
+
+	result.Status = "Market data updated"
+	if len(result.Quarantined) > 0 {
+		result.Status = "Market data updated; some prices held for review"
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// recordMarketSideStores updates everything derived from newly stored
+// prices. Failures are logged; latest is already written by then.
+func recordMarketSideStores(ctx context.Context, accepted map[string]interface{}, now time.Time) {
+	// Keep the per-symbol fallback used when a symbol drops out of latest
+	if err := recordLastKnownPrices(ctx, accepted, now); err != nil {
+		log.Printf("Error recording last known prices: %v", err)
+	}
+	// Both latest and last_known are written now, so the next read sees them
+	quoteCache.Invalidate()
+
+	// Append today's record to each symbol's price history
+	if err := recordPriceHistory(ctx, accepted, now); err != nil {
+		log.Printf("Error recording price history: %v", err)
+	}
+
+	// Refresh company names in the symbol directory
+	if err := recordSymbolNames(ctx, accepted, now); err != nil {
+		log.Printf("Error recording symbol names: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a signed fixed-point number with four fractional digits, stored
+// as an integer count of 1/10000ths. Four places keep per-share averages exact
+// enough while money is rounded to currency precision at the engine boundary.
+type Decimal int64
+
+const (
+	decimalPlaces = 4
+	decimalScale  = 10000
+
+	// currencyPlaces is the precision amounts are reported and reconciled at
+	currencyPlaces = 2
+)
+
+var decimalHundred = NewDecimalFromInt(100)
+
+// NewDecimalFromInt saturates at the largest representable magnitude rather
+// than wrapping around.
+func NewDecimalFromInt(i int64) Decimal {
+	switch {
+	case i > math.MaxInt64/decimalScale:
+		return math.MaxInt64
+	case i < math.MinInt64/decimalScale:
+		return math.MinInt64
+	}
+	return Decimal(i * decimalScale)
+}
+
+// NewDecimalFromFloat converts a stored float, rounding half away from zero
+// so that values like 0.1 map onto their intended decimal.
+func NewDecimalFromFloat(f float64) Decimal {
+	return Decimal(math.Round(f * decimalScale))
+}
+
+// ParseDecimal parses a plain decimal string such as "-1234.5678". Digits
+// beyond four places are rounded half away from zero.
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+
+	roundUp := false
+	if len(fracPart) > decimalPlaces {
+		roundUp = fracPart[decimalPlaces] >= '5'
+		fracPart = fracPart[:decimalPlaces]
+	}
+	fracPart += strings.Repeat("0", decimalPlaces-len(fracPart))
+
+	units, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q: %w", s, err)
+	}
+	if roundUp {
+		units++
+	}
+	if neg {
+		units = -units
+	}
+	return Decimal(units), nil
+}
+
+// mustParseDecimal is for package-level constants only
+func mustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+func (d Decimal) IsZero() bool { return d == 0 }
+
+func (d Decimal) Abs() Decimal {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Mul returns d*o rounded half away from zero to four places
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal(mulDivRound(int64(d), int64(o), decimalScale))
+}
+
+// Div returns d/o rounded half away from zero to four places. Division by
+// zero yields zero, which is what every caller in the engine wants.
+func (d Decimal) Div(o Decimal) Decimal {
+	if o == 0 {
+		return 0
+	}
+	return Decimal(mulDivRound(int64(d), decimalScale, int64(o)))
+}
+
+// Round rounds half away from zero to the given number of fractional digits
+func (d Decimal) Round(places int) Decimal {
+	if places >= decimalPlaces {
+		return d
+	}
+	step := int64(math.Pow10(decimalPlaces - places))
+	return Decimal(mulDivRound(int64(d), 1, step) * step)
+}
+
+// RoundCurrency rounds to the precision broker statements are issued in
+func (d Decimal) RoundCurrency() Decimal {
+	return d.Round(currencyPlaces)
+}
+
+// StringFixed formats with exactly the given number of fractional digits
+func (d Decimal) StringFixed(places int) string {
+	if places > decimalPlaces {
+		places = decimalPlaces
+	}
+	r := d.Round(places)
+	sign := ""
+	if r < 0 {
+		sign = "-"
+		r = -r
+	}
+	units := int64(r)
+	whole := units / decimalScale
+	if places == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	frac := fmt.Sprintf("%04d", units%decimalScale)[:places]
+	return fmt.Sprintf("%s%d.%s", sign, whole, frac)
+}
+
+// String returns the shortest exact representation, e.g. "1234.5" or "100"
+func (d Decimal) String() string {
+	s := d.StringFixed(decimalPlaces)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// MarshalJSON emits a JSON number with no binary floating point artefacts
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts either a JSON number or a quoted decimal string
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		return nil
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		// Exponent forms such as 1e-7 are valid JSON numbers
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil {
+			return err
+		}
+		v = NewDecimalFromFloat(f)
+	}
+	*d = v
+	return nil
+}
+
+// mulDivRound computes a*b/c rounded half away from zero without overflowing
+// the intermediate product. A result beyond int64 saturates.
+func mulDivRound(a, b, c int64) int64 {
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(c)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		if q.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return q.Int64()
+}
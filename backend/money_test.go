@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Decimal
+	}{
+		{"1234.5678", 12345678},
+		{"100", 1000000},
+		{"-1.5", -15000},
+		{"+2", 20000},
+		{" 3 ", 30000},
+		{".5", 5000},
+		{"1.", 10000},
+		{"-0", 0},
+		// Digits beyond four places round half away from zero
+		{"1.23454", 12345},
+		{"1.23455", 12346},
+		{"-1.23455", -12346},
+		{"0.00004", 0},
+		{"0.99995", 10000},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecimalRejects(t *testing.T) {
+	for _, in := range []string{
+		"", " ", "-", ".", "abc", "1.2.3", "1,000", "--1", "1e5", "12a", "0x10",
+		"99999999999999999", // beyond int64 once scaled
+	} {
+		if got, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestDecimalMulDiv(t *testing.T) {
+	d := mustParseDecimal
+	tests := []struct {
+		name string
+		got  Decimal
+		want Decimal
+	}{
+		{"mul exact", d("1.5").Mul(d("1.5")), d("2.25")},
+		{"mul rounds half up", d("0.0001").Mul(d("0.5")), d("0.0001")},
+		{"mul rounds half away from zero", d("-0.0001").Mul(d("0.5")), d("-0.0001")},
+		{"mul rounds down", d("0.0001").Mul(d("0.4")), 0},
+		{"mul large", d("900000").Mul(d("100000")), d("90000000000")},
+		{"div exact", d("10").Div(d("4")), d("2.5")},
+		{"div rounds down", d("1").Div(d("3")), d("0.3333")},
+		{"div rounds up", d("2").Div(d("3")), d("0.6667")},
+		{"div negative", d("-2").Div(d("3")), d("-0.6667")},
+		{"div negative divisor", d("2").Div(d("-3")), d("-0.6667")},
+		{"div by zero", d("5").Div(0), 0},
+		{"mul saturates", Decimal(math.MaxInt64).Mul(d("2")), math.MaxInt64},
+		{"mul saturates negative", Decimal(math.MaxInt64).Mul(d("-2")), math.MinInt64},
+		{"div saturates", Decimal(math.MaxInt64).Div(d("0.5")), math.MaxInt64},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNewDecimalFromIntSaturates(t *testing.T) {
+	if got := NewDecimalFromInt(42); got != 420000 {
+		t.Errorf("NewDecimalFromInt(42) = %d", got)
+	}
+	if got := NewDecimalFromInt(math.MaxInt64); got != math.MaxInt64 {
+		t.Errorf("NewDecimalFromInt(MaxInt64) = %d, want MaxInt64", got)
+	}
+	if got := NewDecimalFromInt(math.MinInt64); got != math.MinInt64 {
+		t.Errorf("NewDecimalFromInt(MinInt64) = %d, want MinInt64", got)
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		in     Decimal
+		want   string
+		fixed2 string
+	}{
+		{0, "0", "0.00"},
+		{1000000, "100", "100.00"},
+		{12345000, "1234.5", "1234.50"},
+		{1, "0.0001", "0.00"},
+		{-500, "-0.05", "-0.05"},
+		{10050, "1.005", "1.01"},
+		{-10050, "-1.005", "-1.01"},
+		{-12345678, "-1234.5678", "-1234.57"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Decimal(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := tt.in.StringFixed(2); got != tt.fixed2 {
+			t.Errorf("Decimal(%d).StringFixed(2) = %q, want %q", tt.in, got, tt.fixed2)
+		}
+		if got, err := ParseDecimal(tt.in.String()); err != nil || got != tt.in {
+			t.Errorf("ParseDecimal(%q) = %d, %v; want a round trip", tt.in.String(), got, err)
+		}
+	}
+}
@@ -1,77 +1,199 @@
 package main
 
 import (
-	"math"
+	"fmt"
+	"sort"
+	"time"
 )
 
 type StockState struct {
-	Qty      float64
-	Cashflow float64
+	Qty      Decimal
+	Cashflow Decimal
+	EverHeld bool
 }
 
-func CalculatePortfolioState(transactions []Transaction, marketPrices map[string]float64, baseNetInvestedOverride *float64) PortfolioSummary {
-	var cashOnHand float64
-	var netInvested float64
+// PortfolioSettings carries the per-user options that influence the engine
+type PortfolioSettings struct {
+	BaseNetInvested *Decimal
+	MarginEnabled   bool
+}
+
+// txOrder breaks ties between transactions on the same timestamp so that
+// inflows are applied before the outflows they fund.
+var txOrder = map[TransactionType]int{
+	DEPOSIT:  0,
+	BUY:      1,
+	DIVIDEND: 2,
+	SELL:     3,
+	WITHDRAW: 4,
+}
+
+// parseTxDate accepts both full RFC3339 timestamps and plain YYYY-MM-DD dates.
+// Both are returned in Colombo time, where a plain date starts at midnight.
+func parseTxDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(colombo), nil
+	}
+	return time.ParseInLocation("2006-01-02", s, colombo)
+}
+
+// sortChronologically returns a copy of transactions ordered by date. Entries
+// whose date cannot be parsed keep their relative order at the end.
+func sortChronologically(transactions []Transaction) ([]Transaction, map[string]bool) {
+	type datedTx struct {
+		Tx    Transaction
+		Date  time.Time
+		Valid bool
+	}
+	dated := make([]datedTx, len(transactions))
+	undated := make(map[string]bool)
+	for i, tx := range transactions {
+		d, err := parseTxDate(tx.Date)
+		dated[i] = datedTx{Tx: tx, Date: d, Valid: err == nil}
+		if err != nil {
+			undated[tx.ID] = true
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		a, b := dated[i], dated[j]
+		if a.Valid != b.Valid {
+			return a.Valid
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return txOrder[a.Tx.Type] < txOrder[b.Tx.Type]
+	})
+
+	sorted := make([]Transaction, len(dated))
+	for i, d := range dated {
+		sorted[i] = d.Tx
+	}
+	return sorted, undated
+}
+
+// CalculatePortfolioState replays transactions in fixed-point arithmetic.
+// Stored float amounts are converted on the way in; every money figure on
+// the result is rounded to currency precision, and totals are sums of the
+// rounded per-holding values so they reconcile exactly.
+func CalculatePortfolioState(transactions []Transaction, marketPrices map[string]PriceQuote, settings PortfolioSettings) PortfolioSummary {
+	var cashOnHand Decimal
+	var netInvested Decimal
+	var diagnostics []Diagnostic
 
 	stockMap := make(map[string]*StockState)
 
-	for _, tx := range transactions {
+	ordered, undated := sortChronologically(transactions)
+
+	for _, tx := range ordered {
+		diag := func(code DiagnosticCode, format string, args ...interface{}) {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:          code,
+				TransactionID: tx.ID,
+				Date:          tx.Date,
+				Symbol:        tx.Symbol,
+				Message:       fmt.Sprintf(format, args...),
+			})
+		}
+
+		if undated[tx.ID] {
+			diag(DiagUnparseableDate, "date %q is not a valid timestamp; applied last", tx.Date)
+		}
+
+		netAmount := NewDecimalFromFloat(tx.NetAmount)
+		qty := NewDecimalFromFloat(tx.Qty)
+
 		// 1. Cash on Hand
-		cashOnHand += tx.NetAmount
+		cashBefore := cashOnHand
+		cashOnHand += netAmount
+		if !settings.MarginEnabled && cashOnHand < 0 && cashBefore >= 0 {
+			diag(DiagNegativeCash, "cash on hand falls to %s and margin is not enabled", cashOnHand.StringFixed(currencyPlaces))
+		}
 
 		// 2. Net Invested
 		if tx.Type == DEPOSIT {
-			netInvested += tx.NetAmount
+			netInvested += netAmount
 		} else if tx.Type == WITHDRAW {
-			netInvested += tx.NetAmount // Assuming netAmount is negative for withdraw
+			netInvested += netAmount // Assuming netAmount is negative for withdraw
 		}
 
 		// 3. Holdings Logic
 		if tx.Symbol != "" {
 			if _, exists := stockMap[tx.Symbol]; !exists {
-				stockMap[tx.Symbol] = &StockState{Qty: 0, Cashflow: 0}
+				stockMap[tx.Symbol] = &StockState{}
 			}
 			stock := stockMap[tx.Symbol]
 
+			// Storage convention (see TransactionForm): BUY qty positive and
+			// netAmount negative, SELL qty negative and netAmount positive.
+			// Entries with the wrong sign are flagged and applied by type.
 			if tx.Type == BUY {
-				stock.Qty += tx.Qty
+				if qty < 0 || netAmount > 0 {
+					diag(DiagSignConvention, "BUY expects positive qty and negative netAmount, got qty %s and netAmount %s", qty, netAmount)
+				}
+				stock.Qty += qty.Abs()
+				if stock.Qty > 0 {
+					stock.EverHeld = true
+				}
 			} else if tx.Type == SELL {
-				stock.Qty += tx.Qty // Qty is negative for sells per logic? Or positive?
-				// JS logic: "If SELL, subtract qty... If tx.qty is -100, adding -100 subtracts."
-				// We need to ensure we follow the storage convention.
-				// Assuming stored types follow same convention as JS app: Qty is -100 for sell.
+				if qty > 0 || netAmount < 0 {
+					diag(DiagSignConvention, "SELL expects negative qty and positive netAmount, got qty %s and netAmount %s", qty, netAmount)
+				}
+				sold := qty.Abs()
+				if sold > stock.Qty {
+					diag(DiagOversell, "sells %s shares but only %s are held", sold, stock.Qty)
+				}
+				stock.Qty -= sold
+			} else if tx.Type == DIVIDEND && !stock.EverHeld {
+				diag(DiagDividendNotHeld, "dividend received on a symbol that was never held before this date")
 			}
 
-			stock.Cashflow += tx.NetAmount
+			stock.Cashflow += netAmount
 		}
 	}
 
-	if baseNetInvestedOverride != nil {
-		netInvested = *baseNetInvestedOverride
+	if settings.BaseNetInvested != nil {
+		netInvested = *settings.BaseNetInvested
 	}
 
 	var holdings []Holding
-	var totalHoldingsValue float64
-	var totalLifecycleGain float64
+	var totalHoldingsValue Decimal
+	var totalLifecycleGain Decimal
+	var missingPrices []string
+	var dayChange Decimal
+	var previousValue Decimal
 
 	for symbol, state := range stockMap {
-		price := 0.0
-		if p, ok := marketPrices[symbol]; ok {
-			price = p
+		quote, ok := marketPrices[symbol]
+		if !ok {
+			quote = PriceQuote{Status: PriceMissing}
 		}
-
-		currentMarketValue := state.Qty * price
-		lifecycleGain := currentMarketValue + state.Cashflow
-
-		// floating point tolerance
-		if math.Abs(state.Qty) > 0.000001 {
-			holdings = append(holdings, Holding{
-				Symbol:        symbol,
-				Qty:           state.Qty,
-				CurrentPrice:  price,
-				MarketValue:   currentMarketValue,
-				LifecycleGain: lifecycleGain,
-			})
+		price := quote.Price
+
+		currentMarketValue := state.Qty.Mul(price).RoundCurrency()
+		lifecycleGain := (currentMarketValue + state.Cashflow).RoundCurrency()
+
+		if !state.Qty.IsZero() {
+			holding := Holding{
+				Symbol:         symbol,
+				Qty:            state.Qty,
+				CurrentPrice:   price,
+				PriceStatus:    quote.Status,
+				PriceTimestamp: quote.AsOf,
+				MarketValue:    currentMarketValue,
+				LifecycleGain:  lifecycleGain,
+			}
+			if quote.Status == PriceLive && quote.PreviousClose > 0 {
+				prevValue := state.Qty.Mul(quote.PreviousClose).RoundCurrency()
+				holding.DayChange = currentMarketValue - prevValue
+				holding.DayChangePct = (price - quote.PreviousClose).Mul(decimalHundred).Div(quote.PreviousClose).Round(currencyPlaces)
+				dayChange += holding.DayChange
+				previousValue += prevValue
+			}
+			holdings = append(holdings, holding)
+			if quote.Status == PriceMissing {
+				missingPrices = append(missingPrices, symbol)
+			}
 		}
 
 		totalHoldingsValue += currentMarketValue
@@ -81,16 +203,23 @@ func CalculatePortfolioState(transactions []Transaction, marketPrices map[string
 	// Calculate Allocation
 	var assetAllocation []Asset
 	for i := range holdings {
-		allocation := 0.0
+		var allocation Decimal
 		if totalHoldingsValue > 0 {
-			allocation = (holdings[i].MarketValue / totalHoldingsValue) * 100
+			allocation = holdings[i].MarketValue.Mul(decimalHundred).Div(totalHoldingsValue).Round(currencyPlaces)
 		}
 		holdings[i].Allocation = allocation
 		assetAllocation = append(assetAllocation, Asset{Name: holdings[i].Symbol, Value: holdings[i].MarketValue})
 	}
 
+	cashOnHand = cashOnHand.RoundCurrency()
+	netInvested = netInvested.RoundCurrency()
 	netWorth := cashOnHand + totalHoldingsValue
 
+	var dayChangePct Decimal
+	if previousValue != 0 {
+		dayChangePct = dayChange.Mul(decimalHundred).Div(previousValue.Abs()).Round(currencyPlaces)
+	}
+
 	return PortfolioSummary{
 		NetWorth:           netWorth,
 		NetInvested:        netInvested,
@@ -98,5 +227,11 @@ func CalculatePortfolioState(transactions []Transaction, marketPrices map[string
 		TotalLifecycleGain: netWorth - netInvested, // Standard definition
 		Holdings:           holdings,
 		AssetAllocation:    assetAllocation,
+		Diagnostics:        diagnostics,
+		DayChange:          dayChange,
+		DayChangePct:       dayChangePct,
+
+		ValuationIncomplete: len(missingPrices) > 0,
+		MissingPrices:       missingPrices,
 	}
 }
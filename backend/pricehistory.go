@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// Daily prices live at market_history/{symbol}/daily/{YYYY-MM-DD}. One
+// document per trading date, so repeated updates on the same day overwrite.
+const (
+	marketHistoryCollection = "market_history"
+	dailyCollection         = "daily"
+)
+
+// colombo is the exchange timezone used to assign trading dates. Sri Lanka
+// has no DST, so a fixed offset is an exact fallback when tzdata is missing.
+var colombo = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Colombo"); err == nil {
+		return loc
+	}
+	return time.FixedZone("+0530", 5*60*60+30*60)
+}()
+
+// PriceRecord is one symbol's stored price for a trading date. Price is the
+// latest (or closing) price; the bar fields are zero when the source did not
+// report them.
+type PriceRecord struct {
+	Date            string  `json:"date" firestore:"date"`
+	Price           float64 `json:"price" firestore:"price"`
+	PreviousClose   float64 `json:"previousClose,omitempty" firestore:"previousClose,omitempty"`
+	ChangePct       float64 `json:"changePct" firestore:"changePct"`
+	Open            float64 `json:"open,omitempty" firestore:"open,omitempty"`
+	High            float64 `json:"high,omitempty" firestore:"high,omitempty"`
+	Low             float64 `json:"low,omitempty" firestore:"low,omitempty"`
+	Volume          float64 `json:"volume,omitempty" firestore:"volume,omitempty"`
+	Trades          float64 `json:"trades,omitempty" firestore:"trades,omitempty"`
+	Turnover        float64 `json:"turnover,omitempty" firestore:"turnover,omitempty"`
+	SourceTimestamp string  `json:"sourceTimestamp" firestore:"sourceTimestamp"`
+	RecordedAt      string  `json:"recordedAt" firestore:"recordedAt"`
+}
+
+// recordPriceHistory appends a dated record per symbol in an update. The
+// source timestamp is taken from the entry when the task supplied one.
+func recordPriceHistory(ctx context.Context, marketData map[string]interface{}, receivedAt time.Time) error {
+	bw := client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for symbol, v := range marketData {
+		entry, ok := parseMarketEntry(v)
+		if !ok || entry.Price <= 0 {
+			continue
+		}
+
+		sourceTime := receivedAt
+		if m, ok := v.(map[string]interface{}); ok {
+			if ts, ok := m["timestamp"].(string); ok {
+				if t, err := time.Parse(time.RFC3339, ts); err == nil {
+					sourceTime = t
+				}
+			}
+		}
+
+		record := PriceRecord{
+			Date:            sourceTime.In(colombo).Format("2006-01-02"),
+			Price:           entry.Price.Float64(),
+			PreviousClose:   entry.PreviousClose.Float64(),
+			ChangePct:       entry.ChangePct.Float64(),
+			Open:            entry.Open.Float64(),
+			High:            entry.High.Float64(),
+			Low:             entry.Low.Float64(),
+			Volume:          entry.Volume.Float64(),
+			Trades:          entry.Trades.Float64(),
+			Turnover:        entry.Turnover.Float64(),
+			SourceTimestamp: sourceTime.UTC().Format(time.RFC3339),
+			RecordedAt:      receivedAt.UTC().Format(time.RFC3339),
+		}
+		ref := client.Collection(marketHistoryCollection).Doc(symbol).Collection(dailyCollection).Doc(record.Date)
+		job, err := bw.Set(ref, record)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchPriceHistory returns a symbol's records with from <= date <= to, oldest first
+func fetchPriceHistory(ctx context.Context, symbol, from, to string) ([]PriceRecord, error) {
+	iter := client.Collection(marketHistoryCollection).Doc(symbol).Collection(dailyCollection).
+		Where("date", ">=", from).
+		Where("date", "<=", to).
+		OrderBy("date", firestore.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	records := []PriceRecord{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rec PriceRecord
+		if err := doc.DataTo(&rec); err != nil {
+			log.Printf("Error mapping price record %s: %v", doc.Ref.Path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// parseDateRange reads from/to (YYYY-MM-DD) query params. to defaults to
+// today in Colombo and from to defaultDays before it.
+func parseDateRange(c *gin.Context, defaultDays int) (string, string, bool) {
+	to := c.Query("to")
+	if to == "" {
+		to = time.Now().In(colombo).Format("2006-01-02")
+	}
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return "", "", false
+	}
+	from := c.Query("from")
+	if from == "" {
+		from = toDate.AddDate(0, 0, -defaultDays).Format("2006-01-02")
+	}
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil || fromDate.After(toDate) {
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// handlePriceHistory serves GET /market/history?symbol=&from=&to=
+func handlePriceHistory(c *gin.Context) {
+	symbol := c.Query("symbol")
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing symbol parameter"})
+		return
+	}
+	from, to, ok := parseDateRange(c, 365)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD with from <= to"})
+		return
+	}
+
+	records, err := fetchPriceHistory(context.Background(), symbol, from, to)
+	if err != nil {
+		log.Printf("Error fetching price history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
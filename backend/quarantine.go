@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// Prices held back by the anomaly guard wait at market_quarantine/{symbol}
+// until an admin accepts or rejects them. One document per symbol; a newer
+// suspicious price replaces an older pending one.
+const quarantineCollection = "market_quarantine"
+
+const (
+	QuarantinePending    = "pending"
+	QuarantineAccepted   = "accepted"
+	QuarantineRejected   = "rejected"
+	QuarantineSuperseded = "superseded" // a normal update arrived first
+)
+
+// defaultMaxMovePct is the largest move against the stored price that is
+// accepted without review. MARKET_MAX_MOVE_PCT overrides it.
+var defaultMaxMovePct = NewDecimalFromInt(30)
+
+// QuarantinedPrice is an incoming price the anomaly guard held back
+type QuarantinedPrice struct {
+	Symbol        string                 `json:"symbol" firestore:"symbol"`
+	Entry         map[string]interface{} `json:"entry" firestore:"entry"` // as it would have been stored
+	Price         float64                `json:"price" firestore:"price"`
+	PreviousPrice float64                `json:"previousPrice" firestore:"previousPrice"`
+	MovePct       float64                `json:"movePct" firestore:"movePct"`
+	Source        string                 `json:"source,omitempty" firestore:"source,omitempty"`
+	ReceivedAt    string                 `json:"receivedAt" firestore:"receivedAt"`
+	Status        string                 `json:"status" firestore:"status"`
+	ResolvedAt    string                 `json:"resolvedAt,omitempty" firestore:"resolvedAt,omitempty"`
+}
+
+func maxMovePct() Decimal {
+	if v := os.Getenv("MARKET_MAX_MOVE_PCT"); v != "" {
+		d, err := ParseDecimal(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Ignoring MARKET_MAX_MOVE_PCT=%q: want a positive number", v)
+	}
+	return defaultMaxMovePct
+}
+
+// movePct is the absolute percentage move from prev to price
+func movePct(prev, price Decimal) Decimal {
+	if prev <= 0 {
+		return 0
+	}
+	return (price - prev).Abs().Mul(decimalHundred).Div(prev)
+}
+
+// screenMarketMoves returns the entries whose price moved more than limit
+// against the stored one. A symbol is exempt when a corporate action takes
+// effect between its stored price's date (prevDates) and today.
+func screenMarketMoves(entries, current map[string]MarketEntry, prevDates map[string]string, actions []CorporateAction, limit Decimal, today string) map[string]Decimal {
+	held := make(map[string]Decimal)
+	for symbol, entry := range entries {
+		prev, ok := current[symbol]
+		if !ok || prev.Price <= 0 {
+			continue // nothing to compare a new listing against
+		}
+		move := movePct(prev.Price, entry.Price)
+		if move <= limit {
+			continue
+		}
+		from := prevDates[symbol]
+		if from == "" {
+			from = today // undated price: only today's actions can explain it
+		}
+		if actionBetween(actions, symbol, from, today) {
+			continue
+		}
+		held[symbol] = move
+	}
+	return held
+}
+
+// storedDate is the Colombo trading date a value in market_data/latest
+// belongs to: its own timestamp if it has one, else the document's.
+func storedDate(v interface{}, docUpdatedAt string) string {
+	ts := docUpdatedAt
+	if m, ok := v.(map[string]interface{}); ok {
+		if s, ok := m["timestamp"].(string); ok {
+			ts = s
+		}
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ""
+	}
+	return t.In(colombo).Format("2006-01-02")
+}
+
+// quarantinePrices writes held-back prices for review
+func quarantinePrices(ctx context.Context, held []QuarantinedPrice) error {
+	if len(held) == 0 {
+		return nil
+	}
+	batch := client.Batch()
+	for _, q := range held {
+		batch.Set(client.Collection(quarantineCollection).Doc(q.Symbol), q)
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// supersedeQuarantine retires pending entries for symbols that have since
+// received a normal price, so accepting them later cannot overwrite it.
+func supersedeQuarantine(ctx context.Context, accepted map[string]interface{}, now time.Time) error {
+	pending, err := fetchQuarantine(ctx, QuarantinePending)
+	if err != nil {
+		return err
+	}
+	batch := client.Batch()
+	n := 0
+	for _, q := range pending {
+		if _, ok := accepted[q.Symbol]; !ok {
+			continue
+		}
+		batch.Update(client.Collection(quarantineCollection).Doc(q.Symbol), []firestore.Update{
+			{Path: "status", Value: QuarantineSuperseded},
+			{Path: "resolvedAt", Value: now.Format(time.RFC3339)},
+		})
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err = batch.Commit(ctx)
+	return err
+}
+
+// fetchQuarantine lists quarantined prices with the given status (all when
+// empty), by symbol
+func fetchQuarantine(ctx context.Context, status string) ([]QuarantinedPrice, error) {
+	q := client.Collection(quarantineCollection).Query
+	if status != "" {
+		q = q.Where("status", "==", status)
+	}
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	held := []QuarantinedPrice{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var qp QuarantinedPrice
+		if err := doc.DataTo(&qp); err != nil {
+			log.Printf("Error mapping quarantined price %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		held = append(held, qp)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Symbol < held[j].Symbol })
+	return held, nil
+}
+
+// handleListQuarantine serves GET /admin/market/quarantine?status= (default pending)
+func handleListQuarantine(c *gin.Context) {
+	status := c.DefaultQuery("status", QuarantinePending)
+	if status == "all" {
+		status = ""
+	}
+	held, err := fetchQuarantine(context.Background(), status)
+	if err != nil {
+		log.Printf("Error fetching quarantine: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantined prices"})
+		return
+	}
+	c.JSON(http.StatusOK, held)
+}
+
+// handleResolveQuarantine serves POST /admin/market/quarantine/:symbol/accept
+// and .../reject. Accepting stores the held price exactly as a normal update
+// would have.
+func handleResolveQuarantine(accept bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.Background()
+		symbol := c.Param("symbol")
+		ref := client.Collection(quarantineCollection).Doc(symbol)
+
+		snap, err := ref.Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No quarantined price for " + symbol})
+			return
+		}
+		var qp QuarantinedPrice
+		if err := snap.DataTo(&qp); err != nil {
+			log.Printf("Error mapping quarantined price %s: %v", symbol, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read quarantined price"})
+			return
+		}
+		if qp.Status != QuarantinePending {
+			c.JSON(http.StatusConflict, gin.H{"error": "Quarantined price is already " + qp.Status})
+			return
+		}
+
+		now := time.Now()
+		qp.Status = QuarantineRejected
+		if accept {
+			qp.Status = QuarantineAccepted
+			entries := map[string]interface{}{symbol: qp.Entry}
+			latestRef := client.Collection(marketCollection).Doc(latestDoc)
+			if _, err := latestRef.Set(ctx, entries, firestore.Merge(firestore.FieldPath{symbol})); err != nil {
+				log.Printf("Error storing accepted price for %s: %v", symbol, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store accepted price"})
+				return
+			}
+			recordMarketSideStores(ctx, entries, now)
+		}
+		qp.ResolvedAt = now.Format(time.RFC3339)
+
+		if _, err := ref.Set(ctx, qp); err != nil {
+			log.Printf("Error resolving quarantined price %s: %v", symbol, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve quarantined price"})
+			return
+		}
+		c.JSON(http.StatusOK, qp)
+	}
+}
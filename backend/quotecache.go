@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// QuoteCache shares one read of market_data/latest and last_known across
+// the burst of summary requests that follows a market update, instead of
+// every user's request reading both documents again. Writes through this
+// instance invalidate it; the TTL bounds how long a write made by another
+// instance can go unseen, which is why snapshots read the documents
+// directly. A failed read is never cached.
+type QuoteCache struct {
+	TTL time.Duration
+
+	group singleflight.Group
+
+	mu       sync.Mutex
+	quotes   map[string]PriceQuote
+	loadedAt time.Time
+	version  int // bumped by Invalidate so an older load is not stored
+}
+
+var quoteCache *QuoteCache
+
+// loadQuoteCache reads MARKET_QUOTE_CACHE_TTL (default 30s; 0 disables caching)
+func loadQuoteCache() *QuoteCache {
+	qc := &QuoteCache{TTL: 30 * time.Second}
+	if v := os.Getenv("MARKET_QUOTE_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			qc.TTL = d
+		} else {
+			log.Printf("Ignoring invalid MARKET_QUOTE_CACHE_TTL %q", v)
+		}
+	}
+	return qc
+}
+
+// Get returns a copy of the market quotes, loading them if the cached set
+// has expired. Concurrent callers share a single load, and the lock is not
+// held while it reads Firestore.
+func (qc *QuoteCache) Get(ctx context.Context) (map[string]PriceQuote, error) {
+	if qc == nil || qc.TTL == 0 {
+		return loadMarketQuotes(ctx)
+	}
+
+	qc.mu.Lock()
+	quotes, version := qc.quotes, qc.version
+	fresh := quotes != nil && time.Since(qc.loadedAt) <= qc.TTL
+	qc.mu.Unlock()
+
+	if !fresh {
+		v, err, _ := qc.group.Do("quotes", func() (interface{}, error) {
+			// Callers share this load, so one caller's cancellation must
+			// not fail the others
+			loaded, err := loadMarketQuotes(context.WithoutCancel(ctx))
+			if err != nil {
+				return nil, err
+			}
+			qc.mu.Lock()
+			if qc.version == version {
+				qc.quotes, qc.loadedAt = loaded, time.Now()
+			}
+			qc.mu.Unlock()
+			return loaded, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		quotes = v.(map[string]PriceQuote)
+	}
+
+	copied := make(map[string]PriceQuote, len(quotes))
+	for k, v := range quotes {
+		copied[k] = v
+	}
+	return copied, nil
+}
+
+// Invalidate drops the cached quotes after latest or last_known changes.
+// A load already in flight is not stored, and later callers start afresh.
+func (qc *QuoteCache) Invalidate() {
+	if qc == nil {
+		return
+	}
+	qc.mu.Lock()
+	qc.quotes = nil
+	qc.version++
+	qc.mu.Unlock()
+	qc.group.Forget("quotes")
+}
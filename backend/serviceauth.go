@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of a signed service request. The signature is the hex
+// HMAC-SHA256, under the secret of the named key, of
+//
+//	METHOD \n PATH?QUERY \n TIMESTAMP \n NONCE \n hex(SHA-256(body))
+//
+// where TIMESTAMP is the X-Service-Timestamp value in Unix seconds and NONCE
+// the X-Service-Nonce value, which is fresh for every request.
+const (
+	serviceKeyHeader       = "X-Service-Key"
+	serviceTimestampHeader = "X-Service-Timestamp"
+	serviceNonceHeader     = "X-Service-Nonce"
+	serviceSignatureHeader = "X-Service-Signature"
+)
+
+// ServiceAuth verifies the signed calls the task makes to internal routes.
+// Several keys may be active at once so a secret can be rotated without
+// downtime: add the new key, move the task over, then drop the old one.
+//
+// A signed request is accepted once. Nonces are remembered for MaxSkew, after
+// which the timestamp check rejects the request anyway. They are kept in
+// memory, so with several backend instances a captured request can still be
+// replayed against another instance within MaxSkew of being signed.
+type ServiceAuth struct {
+	Keys     map[string][]byte // key id -> shared secret
+	MaxSkew  time.Duration     // how far a timestamp may be from now
+	Disabled bool              // SERVICE_AUTH_DISABLED: accept unsigned requests
+
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce -> when it may be forgotten
+}
+
+var serviceAuth *ServiceAuth
+
+// maxServiceBody bounds the body read to check a signature. Key ids are not
+// secret, so this is read before the caller is known to hold a key.
+const maxServiceBody = 8 << 20
+
+// maxServiceNonce bounds the nonces held in memory; the task sends 32 hex digits
+const maxServiceNonce = 64
+
+// loadServiceAuth reads:
+//
+//	SERVICE_KEYS           comma-separated id:secret pairs, e.g. "2024a:s3cret,2025a:n3w"
+//	SERVICE_MAX_SKEW       accepted clock difference and replay window, default 5m
+//	SERVICE_AUTH_DISABLED  "true" to accept unsigned requests, for local development
+//
+// With no keys configured the internal routes refuse every request unless
+// auth is explicitly disabled. If SERVICE_KEYS is set but none of its entries
+// parse, startup fails rather than guessing what was meant.
+func loadServiceAuth() *ServiceAuth {
+	auth := &ServiceAuth{
+		Keys:    make(map[string][]byte),
+		MaxSkew: 5 * time.Minute,
+		seen:    make(map[string]time.Time),
+	}
+
+	keys := os.Getenv("SERVICE_KEYS")
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			log.Printf("Ignoring malformed SERVICE_KEYS entry (want id:secret)")
+			continue
+		}
+		auth.Keys[id] = []byte(secret)
+	}
+	if v := os.Getenv("SERVICE_MAX_SKEW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			auth.MaxSkew = d
+		} else {
+			log.Printf("Ignoring invalid SERVICE_MAX_SKEW %q", v)
+		}
+	}
+
+	auth.Disabled = os.Getenv("SERVICE_AUTH_DISABLED") == "true"
+
+	switch {
+	case len(auth.Keys) == 0 && strings.TrimSpace(keys) != "":
+		log.Fatal("Error: SERVICE_KEYS is set but has no valid id:secret entry.")
+	case auth.Disabled:
+		log.Println("Warning: SERVICE_AUTH_DISABLED is set; internal routes accept unsigned requests.")
+	case len(auth.Keys) == 0:
+		log.Println("Warning: SERVICE_KEYS is not set; internal routes will refuse every request.")
+	default:
+		log.Printf("Service auth enabled with %d key(s).", len(auth.Keys))
+	}
+	return auth
+}
+
+// serviceSignature computes the signature of a request under secret
+func serviceSignature(secret []byte, method, pathAndQuery, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + pathAndQuery + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a request's signature, restoring its body for the handler.
+// On failure it says why, for the log only.
+func (a *ServiceAuth) verify(w http.ResponseWriter, r *http.Request, now time.Time) (string, bool) {
+	keyID := r.Header.Get(serviceKeyHeader)
+	secret, ok := a.Keys[keyID]
+	if !ok {
+		return "unknown key " + strconv.Quote(keyID), false
+	}
+
+	timestamp := r.Header.Get(serviceTimestampHeader)
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "bad timestamp", false
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > a.MaxSkew || skew < -a.MaxSkew {
+		return "timestamp outside " + a.MaxSkew.String() + " window", false
+	}
+	nonce := r.Header.Get(serviceNonceHeader)
+	if nonce == "" || len(nonce) > maxServiceNonce {
+		return "missing or oversized nonce", false
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxServiceBody)); err != nil {
+			return "reading body: " + err.Error(), false
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	pathAndQuery := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		pathAndQuery += "?" + r.URL.RawQuery
+	}
+	want := serviceSignature(secret, r.Method, pathAndQuery, timestamp, nonce, body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(serviceSignatureHeader))) {
+		return "signature mismatch for key " + strconv.Quote(keyID), false
+	}
+	// Only signed nonces are remembered, so nobody without a key can fill the map
+	if !a.firstUse(nonce, time.Unix(sec, 0).Add(a.MaxSkew), now) {
+		return "replayed nonce for key " + strconv.Quote(keyID), false
+	}
+	return "", true
+}
+
+// firstUse records nonce until expires and reports whether it was new.
+// Expired nonces are dropped as it goes.
+func (a *ServiceAuth) firstUse(nonce string, expires, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for n, exp := range a.seen {
+		if now.After(exp) {
+			delete(a.seen, n)
+		}
+	}
+	if _, ok := a.seen[nonce]; ok {
+		return false
+	}
+	a.seen[nonce] = expires
+	return true
+}
+
+// requireServiceAuth guards routes only the task and operators should call
+func requireServiceAuth(c *gin.Context) {
+	if serviceAuth != nil && serviceAuth.Disabled {
+		c.Next()
+		return
+	}
+	if serviceAuth == nil || len(serviceAuth.Keys) == 0 {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service auth is not configured"})
+		return
+	}
+	if reason, ok := serviceAuth.verify(c.Writer, c.Request, time.Now()); !ok {
+		log.Printf("Rejected service call %s %s: %s", c.Request.Method, c.Request.URL.Path, reason)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing service signature"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// buildSnapshot values a user's portfolio with quotes and returns the
+// users/{uid}/history record for it. Firestore stores plain numbers,
+// already rounded to cents.
+func buildSnapshot(ctx context.Context, uid string, quotes map[string]PriceQuote, now time.Time) (map[string]interface{}, error) {
+	transactions, err := fetchTransactions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	settings := loadPortfolioSettings(ctx, uid)
+	summary := CalculatePortfolioState(transactions, quotes, settings)
+	return snapshotRecord(summary, now), nil
+}
+
+// historyDocID names a user's history document for the Colombo date of t.
+// One document per day makes saving a snapshot safe to repeat: a retried
+// or re-run snapshot overwrites that day's point instead of adding another.
+func historyDocID(t time.Time) string {
+	return t.In(colombo).Format("2006-01-02")
+}
+
+// snapshotRecord is the history record for a summary valued at t
+func snapshotRecord(summary PortfolioSummary, t time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"date":                t.Format(time.RFC3339),
+		"netWorth":            summary.NetWorth.Float64(),
+		"netInvested":         summary.NetInvested.Float64(),
+		"cashOnHand":          summary.CashOnHand.Float64(),
+		"totalGain":           summary.TotalLifecycleGain.Float64(),
+		"holdingsCount":       len(summary.Holdings),
+		"dayChange":           summary.DayChange.Float64(),
+		"valuationIncomplete": summary.ValuationIncomplete,
+	}
+}
+
+// overlayQuotes returns the user's quotes with the valid entries of a
+// market update body taking the place of stored live prices, as they would
+// after the update was stored
+func overlayQuotes(ctx context.Context, uid string, marketData map[string]interface{}) (map[string]PriceQuote, error) {
+	quotes, err := loadMarketQuotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asOf := time.Now().Format(time.RFC3339)
+	for symbol, v := range marketData {
+		if _, entry, err := validateMarketEntry(symbol, v); err == nil {
+			quotes[symbol] = PriceQuote{Price: entry.Price, PreviousClose: entry.PreviousClose, Status: PriceLive, AsOf: asOf}
+		}
+	}
+	return withManualPrices(ctx, uid, quotes), nil
+}
+
+// handleSnapshot serves POST /portfolio/snapshot?uid=
+//
+// With ?dryRun=true nothing is saved: the snapshot is computed and
+// returned, and a body in the POST /market/update format, if given, values
+// the portfolio as if those prices had been stored.
+func handleSnapshot(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+	dryRun := c.Query("dryRun") == "true"
+
+	// The caller's deadline and cancellation reach every read and the
+	// write, so a snapshot the task has given up on is not saved late
+	ctx := c.Request.Context()
+	var quotes map[string]PriceQuote
+	var err error
+	if dryRun && c.Request.ContentLength > 0 {
+		var marketData map[string]interface{}
+		if err := c.BindJSON(&marketData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+		quotes, err = overlayQuotes(ctx, uid, marketData)
+	} else {
+		// Snapshots read the stored prices rather than the quote cache: the
+		// market update before them may have gone through another instance
+		quotes, err = loadMarketQuotes(ctx)
+		if err == nil {
+			quotes = withManualPrices(ctx, uid, quotes)
+		}
+	}
+	if err != nil {
+		log.Printf("Error loading market prices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market data"})
+		return
+	}
+
+	now := time.Now()
+	snapshot, err := buildSnapshot(ctx, uid, quotes, now)
+	if err != nil {
+		log.Printf("Error fetching transactions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"status": "Dry run: snapshot not saved", "data": snapshot})
+		return
+	}
+
+	if _, err := client.Collection("users").Doc(uid).Collection("history").Doc(historyDocID(now)).Set(ctx, snapshot); err != nil {
+		log.Printf("Error saving snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snapshot"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Snapshot saved", "data": snapshot})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/api/iterator"
+)
+
+// fetchTransactions loads users/{uid}/transactions in storage order
+func fetchTransactions(ctx context.Context, uid string) ([]Transaction, error) {
+	iter := client.Collection("users").Doc(uid).Collection("transactions").Documents(ctx)
+	defer iter.Stop()
+
+	var transactions []Transaction
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var tx Transaction
+		if err := doc.DataTo(&tx); err != nil {
+			log.Printf("Error mapping tx: %v", err)
+			continue
+		}
+		if tx.ID == "" {
+			tx.ID = doc.Ref.ID
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// fetchGeneralSettings returns users/{uid}/settings/general, or nil if absent
+func fetchGeneralSettings(ctx context.Context, uid string) map[string]interface{} {
+	snap, err := client.Collection("users").Doc(uid).Collection("settings").Doc("general").Get(ctx)
+	if err != nil || !snap.Exists() {
+		return nil
+	}
+	return snap.Data()
+}
+
+// loadPortfolioSettings reads the engine options from settings/general
+func loadPortfolioSettings(ctx context.Context, uid string) PortfolioSettings {
+	var settings PortfolioSettings
+	data := fetchGeneralSettings(ctx, uid)
+
+	if v, ok := decimalFromValue(data["baseBankTransfer"]); ok {
+		settings.BaseNetInvested = &v
+	}
+	if v, ok := data["marginEnabled"].(bool); ok {
+		settings.MarginEnabled = v
+	}
+	return settings
+}
+
+// decimalFromValue converts a Firestore number, which may come back as
+// float64 or int64 depending on how it was written.
+func decimalFromValue(v interface{}) (Decimal, bool) {
+	switch val := v.(type) {
+	case float64:
+		return NewDecimalFromFloat(val), true
+	case int64:
+		return NewDecimalFromInt(val), true
+	case int:
+		return NewDecimalFromInt(int64(val)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// symbols/{symbol} holds directory metadata. name and lastSeen are refreshed
+// by every market update; board, sector and status are curated by hand via
+// PUT /market/symbols/:symbol because the CSE trade summary does not carry them.
+const symbolsCollection = "symbols"
+
+type ListingStatus string
+
+const (
+	StatusListed    ListingStatus = "listed"
+	StatusSuspended ListingStatus = "suspended"
+	StatusDelisted  ListingStatus = "delisted"
+	StatusUnlisted  ListingStatus = "unlisted"
+)
+
+// SymbolInfo is one entry in the symbol directory
+type SymbolInfo struct {
+	Symbol   string        `json:"symbol" firestore:"symbol"`
+	Name     string        `json:"name,omitempty" firestore:"name,omitempty"`
+	Board    string        `json:"board,omitempty" firestore:"board,omitempty"`
+	Sector   string        `json:"sector,omitempty" firestore:"sector,omitempty"`
+	Status   ListingStatus `json:"status" firestore:"status,omitempty"`
+	LastSeen string        `json:"lastSeen,omitempty" firestore:"lastSeen,omitempty"`
+}
+
+// symbolDirectoryTTL bounds how long search results can lag a manual edit
+// made directly in Firestore; edits through the API invalidate immediately.
+const symbolDirectoryTTL = 5 * time.Minute
+
+var symbolDirectory struct {
+	sync.Mutex
+	entries  []SymbolInfo
+	loadedAt time.Time
+}
+
+func invalidateSymbolDirectory() {
+	symbolDirectory.Lock()
+	symbolDirectory.entries = nil
+	symbolDirectory.Unlock()
+}
+
+// loadSymbolDirectory returns every symbol with metadata, including symbols
+// present in market_data/latest that have never had metadata written.
+func loadSymbolDirectory(ctx context.Context) ([]SymbolInfo, error) {
+	symbolDirectory.Lock()
+	defer symbolDirectory.Unlock()
+	if symbolDirectory.entries != nil && time.Since(symbolDirectory.loadedAt) < symbolDirectoryTTL {
+		return symbolDirectory.entries, nil
+	}
+
+	bySymbol := make(map[string]SymbolInfo)
+	iter := client.Collection(symbolsCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var info SymbolInfo
+		if err := doc.DataTo(&info); err != nil {
+			log.Printf("Error mapping symbol %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		info.Symbol = doc.Ref.ID
+		bySymbol[info.Symbol] = info
+	}
+
+	quotes, err := loadMarketQuotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for symbol := range quotes {
+		if _, ok := bySymbol[symbol]; !ok {
+			bySymbol[symbol] = SymbolInfo{Symbol: symbol}
+		}
+	}
+
+	entries := make([]SymbolInfo, 0, len(bySymbol))
+	for _, info := range bySymbol {
+		if info.Status == "" {
+			info.Status = StatusListed
+		}
+		entries = append(entries, info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Symbol < entries[j].Symbol })
+
+	symbolDirectory.entries = entries
+	symbolDirectory.loadedAt = time.Now()
+	return entries, nil
+}
+
+// recordSymbolNames upserts the company name reported for each symbol in a
+// market update, leaving curated fields alone.
+func recordSymbolNames(ctx context.Context, marketData map[string]interface{}, seenAt time.Time) error {
+	bw := client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for symbol, v := range marketData {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		update := map[string]interface{}{
+			"symbol":   symbol,
+			"lastSeen": seenAt.UTC().Format(time.RFC3339),
+		}
+		if name, ok := m["name"].(string); ok && name != "" {
+			update["name"] = name
+		}
+		job, err := bw.Set(client.Collection(symbolsCollection).Doc(symbol), update, firestore.MergeAll)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+	invalidateSymbolDirectory()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeSearch lowercases and drops punctuation so "J.K.H" matches "jkh"
+func normalizeSearch(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// isSubsequence reports whether every rune of needle appears in haystack in order
+func isSubsequence(needle, haystack string) bool {
+	h := []rune(haystack)
+	i := 0
+	for _, r := range needle {
+		for i < len(h) && h[i] != r {
+			i++
+		}
+		if i == len(h) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// withinOneEdit reports whether a and b differ by at most one insertion,
+// deletion or substitution.
+func withinOneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(rb)-len(ra) > 1 {
+		return false
+	}
+	i, j, edits := 0, 0, 0
+	for i < len(ra) && j < len(rb) {
+		if ra[i] == rb[j] {
+			i++
+			j++
+			continue
+		}
+		edits++
+		if edits > 1 {
+			return false
+		}
+		if len(ra) == len(rb) {
+			i++
+		}
+		j++
+	}
+	return edits+(len(rb)-j)+(len(ra)-i) <= 1
+}
+
+// scoreSymbol ranks how well a query matches a directory entry; 0 means no
+// match. Symbol hits beat name hits, prefixes beat substrings, and loose
+// subsequence or one-typo matches come last.
+func scoreSymbol(info SymbolInfo, query string) int {
+	symbol := normalizeSearch(info.Symbol)
+	ticker, _, _ := strings.Cut(strings.ToLower(info.Symbol), ".")
+	name := normalizeSearch(info.Name)
+	compactQuery := strings.ReplaceAll(query, " ", "")
+
+	switch {
+	case ticker == compactQuery || symbol == compactQuery:
+		return 100
+	case strings.HasPrefix(symbol, compactQuery):
+		return 80
+	case name != "" && strings.HasPrefix(name, query):
+		return 70
+	}
+
+	words := strings.Fields(name)
+	allTokensPrefix := len(words) > 0
+	anyTypo := false
+	for _, token := range strings.Fields(query) {
+		matched := false
+		for _, w := range words {
+			if strings.HasPrefix(w, token) {
+				matched = true
+				break
+			}
+			if len(token) >= 4 && withinOneEdit(token, w) {
+				anyTypo = true
+			}
+		}
+		if !matched {
+			allTokensPrefix = false
+		}
+	}
+
+	switch {
+	case allTokensPrefix:
+		return 60
+	case strings.Contains(symbol, compactQuery) || (name != "" && strings.Contains(name, query)):
+		return 40
+	case name != "" && isSubsequence(compactQuery, strings.ReplaceAll(name, " ", "")):
+		return 20
+	case anyTypo:
+		return 15
+	}
+	return 0
+}
+
+// searchSymbols returns entries matching query, best first. An empty query
+// returns the whole directory in symbol order.
+func searchSymbols(entries []SymbolInfo, query string, limit int) []SymbolInfo {
+	q := normalizeSearch(query)
+	results := []SymbolInfo{}
+	if q == "" {
+		results = append(results, entries...)
+	} else {
+		type scored struct {
+			info  SymbolInfo
+			score int
+		}
+		var matches []scored
+		for _, info := range entries {
+			if s := scoreSymbol(info, q); s > 0 {
+				matches = append(matches, scored{info, s})
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+		for _, m := range matches {
+			results = append(results, m.info)
+		}
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// handleSymbolSearch serves GET /market/symbols/search?q=&limit=
+func handleSymbolSearch(c *gin.Context) {
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+			return
+		}
+		limit = n
+	}
+
+	entries, err := loadSymbolDirectory(context.Background())
+	if err != nil {
+		log.Printf("Error loading symbol directory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch symbols"})
+		return
+	}
+	c.JSON(http.StatusOK, searchSymbols(entries, c.Query("q"), limit))
+}
+
+// handleUpdateSymbol serves PUT /market/symbols/:symbol for curating board,
+// sector, listing status and name. Fields omitted from the body are kept.
+func handleUpdateSymbol(c *gin.Context) {
+	symbol := c.Param("symbol")
+
+	var body struct {
+		Name   *string        `json:"name"`
+		Board  *string        `json:"board"`
+		Sector *string        `json:"sector"`
+		Status *ListingStatus `json:"status"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	update := map[string]interface{}{"symbol": symbol}
+	if body.Name != nil {
+		update["name"] = *body.Name
+	}
+	if body.Board != nil {
+		update["board"] = *body.Board
+	}
+	if body.Sector != nil {
+		update["sector"] = *body.Sector
+	}
+	if body.Status != nil {
+		switch *body.Status {
+		case StatusListed, StatusSuspended, StatusDelisted, StatusUnlisted:
+			update["status"] = string(*body.Status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be listed, suspended, delisted or unlisted"})
+			return
+		}
+	}
+
+	ctx := context.Background()
+	ref := client.Collection(symbolsCollection).Doc(symbol)
+	if _, err := ref.Set(ctx, update, firestore.MergeAll); err != nil {
+		log.Printf("Error updating symbol %s: %v", symbol, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update symbol"})
+		return
+	}
+	invalidateSymbolDirectory()
+
+	snap, err := ref.Get(ctx)
+	if err != nil {
+		log.Printf("Error reading back symbol %s: %v", symbol, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read back symbol"})
+		return
+	}
+	var info SymbolInfo
+	if err := snap.DataTo(&info); err != nil {
+		log.Printf("Error mapping symbol %s: %v", symbol, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read back symbol"})
+		return
+	}
+	info.Symbol = symbol
+	if info.Status == "" {
+		info.Status = StatusListed
+	}
+	c.JSON(http.StatusOK, info)
+}
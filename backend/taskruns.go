@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// task_runs holds one document per run of the task, so a failed nightly
+// run shows up here rather than only in container logs
+const taskRunsCollection = "task_runs"
+
+// Run outcomes. The task reports one; for a record without, it is derived
+// from the exit code.
+const (
+	RunSucceeded = "succeeded"
+	RunSkipped   = "skipped" // not a trading day
+	RunPartial   = "partial" // prices stored, some snapshots failed
+	RunFailed    = "failed"
+)
+
+// Exit codes of the task (task/pipeline.go) that runOutcome tells apart;
+// every other non-zero code is a failure
+const (
+	taskExitOK      = 0
+	taskExitPartial = 13
+)
+
+// maxRunErrors bounds the error list stored with one run
+const maxRunErrors = 50
+
+// TaskRun is one run as reported by the task. Times are RFC3339 UTC so
+// they sort as strings.
+type TaskRun struct {
+	ID              string   `json:"id" firestore:"-"`            // chosen by the task, so a retried record overwrites itself
+	Command         string   `json:"command" firestore:"command"` // run, scrape, import-prices, snapshot, backfill
+	Trigger         string   `json:"trigger" firestore:"trigger"` // scheduled, manual or cli
+	Start           string   `json:"start" firestore:"start"`
+	End             string   `json:"end" firestore:"end"`
+	DurationMs      int64    `json:"durationMs" firestore:"durationMs"`
+	ExitCode        int      `json:"exitCode" firestore:"exitCode"`
+	Outcome         string   `json:"outcome" firestore:"outcome"`
+	Skipped         string   `json:"skipped,omitempty" firestore:"skipped,omitempty"` // why nothing was done
+	Source          string   `json:"source,omitempty" firestore:"source,omitempty"`
+	Symbols         int      `json:"symbols" firestore:"symbols"` // quotes fetched from the source
+	Snapshots       int      `json:"snapshots" firestore:"snapshots"`
+	FailedSnapshots int      `json:"failedSnapshots" firestore:"failedSnapshots"`
+	FailedUsers     []string `json:"failedUsers,omitempty" firestore:"failedUsers,omitempty"`
+	Error           string   `json:"error,omitempty" firestore:"error,omitempty"`
+	Errors          []string `json:"errors,omitempty" firestore:"errors,omitempty"`
+	RecordedAt      string   `json:"recordedAt" firestore:"recordedAt"`
+}
+
+// runOutcome maps the task's exit codes to an outcome
+func runOutcome(exitCode int, skipped string) string {
+	switch {
+	case exitCode == taskExitOK && skipped != "":
+		return RunSkipped
+	case exitCode == taskExitOK:
+		return RunSucceeded
+	case exitCode == taskExitPartial:
+		return RunPartial
+	}
+	return RunFailed
+}
+
+// validRunID accepts ids that are safe as a document id
+func validRunID(id string) bool {
+	return len(id) <= 128 && !strings.Contains(id, "/") && id != "." && id != ".." && !strings.HasPrefix(id, "__")
+}
+
+// normalizeRunTime re-formats an RFC3339 time in UTC
+func normalizeRunTime(s string) (string, time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return t.UTC().Format(time.RFC3339), t, true
+}
+
+// handleRecordTaskRun serves POST /admin/task-runs. A run with an id is
+// stored under it, so recording the same run twice keeps one record.
+func handleRecordTaskRun(c *gin.Context) {
+	var run TaskRun
+	if err := c.BindJSON(&run); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if !validRunID(run.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be at most 128 characters without /"})
+		return
+	}
+	start, startTime, ok := normalizeRunTime(run.Start)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be an RFC3339 time"})
+		return
+	}
+	run.Start = start
+	if end, endTime, ok := normalizeRunTime(run.End); ok {
+		run.End = end
+		run.DurationMs = endTime.Sub(startTime).Milliseconds()
+	} else {
+		run.End = ""
+	}
+	if run.Command == "" {
+		run.Command = "run"
+	}
+	if len(run.Errors) > maxRunErrors {
+		run.Errors = append(run.Errors[:maxRunErrors], "(more errors not recorded)")
+	}
+	switch run.Outcome {
+	case RunSucceeded, RunSkipped, RunPartial, RunFailed:
+	default:
+		run.Outcome = runOutcome(run.ExitCode, run.Skipped)
+	}
+	run.RecordedAt = time.Now().UTC().Format(time.RFC3339)
+
+	runs := client.Collection(taskRunsCollection)
+	ref := runs.NewDoc()
+	if run.ID != "" {
+		ref = runs.Doc(run.ID)
+	}
+	if _, err := ref.Set(context.Background(), run); err != nil {
+		log.Printf("Error saving task run: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save task run"})
+		return
+	}
+	run.ID = ref.ID
+	c.JSON(http.StatusOK, run)
+}
+
+// handleListTaskRuns serves GET /admin/task-runs?limit=&outcome=, newest first
+func handleListTaskRuns(c *gin.Context) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		limit = n
+	}
+	outcome := c.Query("outcome")
+	switch outcome {
+	case "", RunSucceeded, RunSkipped, RunPartial, RunFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "outcome must be succeeded, skipped, partial or failed"})
+		return
+	}
+
+	// Filtering on outcome here rather than in the query avoids needing a
+	// composite index; it looks back over the most recent 500 runs.
+	fetch := limit
+	if outcome != "" {
+		fetch = 500
+	}
+	iter := client.Collection(taskRunsCollection).OrderBy("start", firestore.Desc).Limit(fetch).Documents(context.Background())
+	defer iter.Stop()
+
+	runs := []TaskRun{}
+	for len(runs) < limit {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("Error fetching task runs: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task runs"})
+			return
+		}
+		var run TaskRun
+		if err := doc.DataTo(&run); err != nil {
+			log.Printf("Error mapping task run %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if outcome != "" && run.Outcome != outcome {
+			continue
+		}
+		run.ID = doc.Ref.ID
+		runs = append(runs, run)
+	}
+	c.JSON(http.StatusOK, runs)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CostBasisMethod selects how the cost of shares sold is matched against purchases
+type CostBasisMethod string
+
+const (
+	AverageCost CostBasisMethod = "AVERAGE"
+	FIFO        CostBasisMethod = "FIFO"
+)
+
+// TaxRules describes how a jurisdiction treats disposals and dividend income
+type TaxRules struct {
+	Jurisdiction            string          `json:"jurisdiction"`
+	FiscalYearStartMonth    time.Month      `json:"fiscalYearStartMonth"`
+	CostBasisMethod         CostBasisMethod `json:"costBasisMethod"`
+	CapitalGainsRate        Decimal         `json:"capitalGainsRate"`
+	CapitalGainsAllowance   Decimal         `json:"capitalGainsAllowance"`
+	DividendWithholdingRate Decimal         `json:"dividendWithholdingRate"`
+}
+
+// taxRuleSets holds the built-in jurisdictions. LK is the default: listed
+// share gains are exempt and dividends suffer 15% WHT at source.
+var taxRuleSets = map[string]TaxRules{
+	"LK": {
+		Jurisdiction:            "LK",
+		FiscalYearStartMonth:    time.April,
+		CostBasisMethod:         AverageCost,
+		CapitalGainsRate:        0,
+		DividendWithholdingRate: mustParseDecimal("0.15"),
+	},
+	"UK": {
+		Jurisdiction:          "UK",
+		FiscalYearStartMonth:  time.April,
+		CostBasisMethod:       AverageCost,
+		CapitalGainsRate:      mustParseDecimal("0.24"),
+		CapitalGainsAllowance: NewDecimalFromInt(3000),
+	},
+	"CALENDAR": {
+		Jurisdiction:         "CALENDAR",
+		FiscalYearStartMonth: time.January,
+		CostBasisMethod:      FIFO,
+	},
+}
+
+const defaultTaxJurisdiction = "LK"
+
+// LookupTaxRules returns the rule set for a jurisdiction code (case-insensitive)
+func LookupTaxRules(code string) (TaxRules, bool) {
+	if code == "" {
+		code = defaultTaxJurisdiction
+	}
+	rules, ok := taxRuleSets[strings.ToUpper(code)]
+	return rules, ok
+}
+
+// Disposal is a single realised gain or loss from a SELL
+type Disposal struct {
+	TransactionID string  `json:"transactionId"`
+	Date          string  `json:"date"`
+	Symbol        string  `json:"symbol"`
+	Qty           Decimal `json:"qty"`
+	Proceeds      Decimal `json:"proceeds"`
+	CostBasis     Decimal `json:"costBasis"`
+	Gain          Decimal `json:"gain"`
+}
+
+// DividendIncome is a single dividend receipt with its withholding tax
+type DividendIncome struct {
+	TransactionID string  `json:"transactionId"`
+	Date          string  `json:"date"`
+	Symbol        string  `json:"symbol"`
+	Gross         Decimal `json:"gross"`
+	TaxWithheld   Decimal `json:"taxWithheld"`
+	Net           Decimal `json:"net"`
+}
+
+type TaxReportTotals struct {
+	Proceeds                 Decimal `json:"proceeds"`
+	CostBasis                Decimal `json:"costBasis"`
+	RealisedGain             Decimal `json:"realisedGain"`
+	EstimatedCapitalGainsTax Decimal `json:"estimatedCapitalGainsTax"`
+	DividendGross            Decimal `json:"dividendGross"`
+	DividendTaxWithheld      Decimal `json:"dividendTaxWithheld"`
+	DividendNet              Decimal `json:"dividendNet"`
+}
+
+// TaxReport is the capital gains and income statement for one fiscal year
+type TaxReport struct {
+	FiscalYear  string           `json:"fiscalYear"`
+	PeriodStart string           `json:"periodStart"`
+	PeriodEnd   string           `json:"periodEnd"`
+	Rules       TaxRules         `json:"rules"`
+	Disposals   []Disposal       `json:"disposals"`
+	Dividends   []DividendIncome `json:"dividends"`
+	Totals      TaxReportTotals  `json:"totals"`
+}
+
+// FiscalYearBounds returns the [start, end) range of the fiscal year that
+// begins in startYear, in Colombo time.
+func FiscalYearBounds(startYear int, startMonth time.Month) (time.Time, time.Time) {
+	start := time.Date(startYear, startMonth, 1, 0, 0, 0, 0, colombo)
+	return start, start.AddDate(1, 0, 0)
+}
+
+// FiscalYearFor returns the starting calendar year of the fiscal year containing t
+func FiscalYearFor(t time.Time, startMonth time.Month) int {
+	if t.Month() < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+func fiscalYearLabel(startYear int, startMonth time.Month) string {
+	if startMonth == time.January {
+		return fmt.Sprintf("%d", startYear)
+	}
+	return fmt.Sprintf("%d/%02d", startYear, (startYear+1)%100)
+}
+
+type taxLot struct {
+	Qty  Decimal
+	Cost Decimal
+}
+
+// BuildTaxReport replays the full transaction history so that cost basis
+// carried in from earlier years is correct, but only reports disposals and
+// dividends dated within the requested fiscal year.
+func BuildTaxReport(transactions []Transaction, rules TaxRules, fiscalYear int) TaxReport {
+	start, end := FiscalYearBounds(fiscalYear, rules.FiscalYearStartMonth)
+
+	ordered, _ := sortChronologically(transactions)
+
+	report := TaxReport{
+		FiscalYear:  fiscalYearLabel(fiscalYear, rules.FiscalYearStartMonth),
+		PeriodStart: start.Format("2006-01-02"),
+		PeriodEnd:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rules:       rules,
+		Disposals:   []Disposal{},
+		Dividends:   []DividendIncome{},
+	}
+
+	lots := make(map[string][]taxLot)
+
+	for _, tx := range ordered {
+		date, err := parseTxDate(tx.Date)
+		if err != nil {
+			continue
+		}
+		inPeriod := !date.Before(start) && date.Before(end)
+
+		switch tx.Type {
+		case BUY:
+			qty := NewDecimalFromFloat(tx.Qty).Abs()
+			cost := -NewDecimalFromFloat(tx.NetAmount)
+			if cost <= 0 {
+				cost = qty.Mul(NewDecimalFromFloat(tx.Price)) + NewDecimalFromFloat(tx.Fee)
+			}
+			lots[tx.Symbol] = addLot(lots[tx.Symbol], taxLot{Qty: qty, Cost: cost}, rules.CostBasisMethod)
+
+		case SELL:
+			qty := NewDecimalFromFloat(tx.Qty).Abs()
+			proceeds := NewDecimalFromFloat(tx.NetAmount)
+			if proceeds <= 0 {
+				proceeds = qty.Mul(NewDecimalFromFloat(tx.Price)) - NewDecimalFromFloat(tx.Fee)
+			}
+			proceeds = proceeds.RoundCurrency()
+			var basis Decimal
+			lots[tx.Symbol], basis = consumeLots(lots[tx.Symbol], qty)
+			basis = basis.RoundCurrency()
+
+			if inPeriod {
+				report.Disposals = append(report.Disposals, Disposal{
+					TransactionID: tx.ID,
+					Date:          date.Format("2006-01-02"),
+					Symbol:        tx.Symbol,
+					Qty:           qty,
+					Proceeds:      proceeds,
+					CostBasis:     basis,
+					Gain:          proceeds - basis,
+				})
+			}
+
+		case DIVIDEND:
+			if !inPeriod {
+				continue
+			}
+			net := NewDecimalFromFloat(tx.NetAmount).RoundCurrency()
+			withheld := NewDecimalFromFloat(tx.TaxWithheld).RoundCurrency()
+			if withheld == 0 && rules.DividendWithholdingRate > 0 && rules.DividendWithholdingRate < NewDecimalFromInt(1) {
+				// Brokers credit dividends net of WHT; gross it back up
+				withheld = (net.Div(NewDecimalFromInt(1)-rules.DividendWithholdingRate) - net).RoundCurrency()
+			}
+			report.Dividends = append(report.Dividends, DividendIncome{
+				TransactionID: tx.ID,
+				Date:          date.Format("2006-01-02"),
+				Symbol:        tx.Symbol,
+				Gross:         net + withheld,
+				TaxWithheld:   withheld,
+				Net:           net,
+			})
+		}
+	}
+
+	for _, d := range report.Disposals {
+		report.Totals.Proceeds += d.Proceeds
+		report.Totals.CostBasis += d.CostBasis
+		report.Totals.RealisedGain += d.Gain
+	}
+	for _, d := range report.Dividends {
+		report.Totals.DividendGross += d.Gross
+		report.Totals.DividendTaxWithheld += d.TaxWithheld
+		report.Totals.DividendNet += d.Net
+	}
+	if taxable := report.Totals.RealisedGain - rules.CapitalGainsAllowance; taxable > 0 {
+		report.Totals.EstimatedCapitalGainsTax = taxable.Mul(rules.CapitalGainsRate).RoundCurrency()
+	}
+
+	return report
+}
+
+// addLot records a purchase. Average cost keeps a single pooled lot per symbol.
+func addLot(lots []taxLot, lot taxLot, method CostBasisMethod) []taxLot {
+	if method == FIFO || len(lots) == 0 {
+		return append(lots, lot)
+	}
+	lots[0].Qty += lot.Qty
+	lots[0].Cost += lot.Cost
+	return lots
+}
+
+// consumeLots removes qty shares from the front of lots and returns the cost
+// attributed to them. Selling more than is held leaves the excess with zero basis.
+func consumeLots(lots []taxLot, qty Decimal) ([]taxLot, Decimal) {
+	var basis Decimal
+	for qty > 0 && len(lots) > 0 {
+		lot := &lots[0]
+		if lot.Qty <= qty {
+			basis += lot.Cost
+			qty -= lot.Qty
+			lots = lots[1:]
+			continue
+		}
+		portion := lot.Cost.Mul(qty).Div(lot.Qty)
+		basis += portion
+		lot.Cost -= portion
+		lot.Qty -= qty
+		qty = 0
+	}
+	return lots, basis
+}
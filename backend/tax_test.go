@@ -0,0 +1,231 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// twoBuysOneSell buys 1000 JKH at 10 and 1000 at 20, then sells 1000 at 25:
+// average cost gives a basis of 15000, FIFO a basis of 10000.
+func twoBuysOneSell(sellDate string) []Transaction {
+	return []Transaction{
+		{ID: "b1", Date: "2024-05-02", Type: BUY, Symbol: "JKH", Qty: 1000, Price: 10, NetAmount: -10000},
+		{ID: "b2", Date: "2024-06-03", Type: BUY, Symbol: "JKH", Qty: 1000, Price: 20, NetAmount: -20000},
+		{ID: "s1", Date: sellDate, Type: SELL, Symbol: "JKH", Qty: 1000, Price: 25, NetAmount: 25000},
+	}
+}
+
+func mustTaxRules(t *testing.T, code string) TaxRules {
+	t.Helper()
+	rules, ok := LookupTaxRules(code)
+	if !ok {
+		t.Fatalf("no tax rules for %q", code)
+	}
+	return rules
+}
+
+func TestBuildTaxReportDisposals(t *testing.T) {
+	d := mustParseDecimal
+	type disposal struct {
+		Date string
+		Gain Decimal
+	}
+	tests := []struct {
+		name         string
+		rules        string
+		method       CostBasisMethod // overrides the rule set's when set
+		year         int
+		transactions []Transaction
+		label        string
+		start, end   string
+		disposals    []disposal
+		cgt          Decimal
+	}{
+		{
+			name:         "LK average cost, gains exempt",
+			rules:        "LK",
+			year:         2024,
+			transactions: twoBuysOneSell("2024-07-01"),
+			label:        "2024/25",
+			start:        "2024-04-01",
+			end:          "2025-03-31",
+			disposals:    []disposal{{"2024-07-01", d("10000")}},
+		},
+		{
+			name:         "LK with FIFO lots",
+			rules:        "LK",
+			method:       FIFO,
+			year:         2024,
+			transactions: twoBuysOneSell("2024-07-01"),
+			label:        "2024/25",
+			start:        "2024-04-01",
+			end:          "2025-03-31",
+			disposals:    []disposal{{"2024-07-01", d("15000")}},
+		},
+		{
+			name:         "UK average cost above the allowance",
+			rules:        "UK",
+			year:         2024,
+			transactions: twoBuysOneSell("2024-07-01"),
+			label:        "2024/25",
+			start:        "2024-04-01",
+			end:          "2025-03-31",
+			disposals:    []disposal{{"2024-07-01", d("10000")}},
+			cgt:          d("1680"), // (10000 - 3000) * 24%
+		},
+		{
+			name:         "CALENDAR uses FIFO",
+			rules:        "CALENDAR",
+			year:         2024,
+			transactions: twoBuysOneSell("2024-07-01"),
+			label:        "2024",
+			start:        "2024-01-01",
+			end:          "2024-12-31",
+			disposals:    []disposal{{"2024-07-01", d("15000")}},
+		},
+		{
+			name:  "FIFO partial lot carries into the next year",
+			rules: "CALENDAR",
+			year:  2025,
+			transactions: []Transaction{
+				{ID: "b1", Date: "2024-05-02", Type: BUY, Symbol: "JKH", Qty: 100, Price: 10, NetAmount: -1000},
+				{ID: "b2", Date: "2024-06-03", Type: BUY, Symbol: "JKH", Qty: 100, Price: 20, NetAmount: -2000},
+				{ID: "s1", Date: "2024-07-01", Type: SELL, Symbol: "JKH", Qty: 50, Price: 25, NetAmount: 1250},
+				{ID: "s2", Date: "2025-02-03", Type: SELL, Symbol: "JKH", Qty: 100, Price: 30, NetAmount: 3000},
+			},
+			label:     "2025",
+			start:     "2025-01-01",
+			end:       "2025-12-31",
+			disposals: []disposal{{"2025-02-03", d("1500")}}, // 50 at 10 and 50 at 20
+		},
+		{
+			name:         "sale before the year is excluded",
+			rules:        "LK",
+			year:         2025,
+			transactions: twoBuysOneSell("2025-03-31"),
+			label:        "2025/26",
+			start:        "2025-04-01",
+			end:          "2026-03-31",
+		},
+		{
+			name:         "plain date on the first day is included",
+			rules:        "LK",
+			year:         2025,
+			transactions: twoBuysOneSell("2025-04-01"),
+			label:        "2025/26",
+			start:        "2025-04-01",
+			end:          "2026-03-31",
+			disposals:    []disposal{{"2025-04-01", d("10000")}},
+		},
+		{
+			name:         "UTC timestamp after Colombo midnight is in the new year",
+			rules:        "LK",
+			year:         2025,
+			transactions: twoBuysOneSell("2025-03-31T20:00:00Z"), // 01:30 on 1 April in Colombo
+			label:        "2025/26",
+			start:        "2025-04-01",
+			end:          "2026-03-31",
+			disposals:    []disposal{{"2025-04-01", d("10000")}},
+		},
+		{
+			name:         "UTC timestamp before Colombo midnight is in the old year",
+			rules:        "LK",
+			year:         2024,
+			transactions: twoBuysOneSell("2025-03-31T18:00:00Z"), // 23:30 on 31 March in Colombo
+			label:        "2024/25",
+			start:        "2024-04-01",
+			end:          "2025-03-31",
+			disposals:    []disposal{{"2025-03-31", d("10000")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := mustTaxRules(t, tt.rules)
+			if tt.method != "" {
+				rules.CostBasisMethod = tt.method
+			}
+			report := BuildTaxReport(tt.transactions, rules, tt.year)
+
+			if report.FiscalYear != tt.label || report.PeriodStart != tt.start || report.PeriodEnd != tt.end {
+				t.Errorf("period = %s %s..%s, want %s %s..%s",
+					report.FiscalYear, report.PeriodStart, report.PeriodEnd, tt.label, tt.start, tt.end)
+			}
+			var got []disposal
+			for _, dp := range report.Disposals {
+				got = append(got, disposal{dp.Date, dp.Gain})
+			}
+			if !reflect.DeepEqual(got, tt.disposals) {
+				t.Errorf("disposals = %v, want %v", got, tt.disposals)
+			}
+			if report.Totals.EstimatedCapitalGainsTax != tt.cgt {
+				t.Errorf("estimated CGT = %s, want %s", report.Totals.EstimatedCapitalGainsTax, tt.cgt)
+			}
+		})
+	}
+}
+
+func TestBuildTaxReportDividends(t *testing.T) {
+	d := mustParseDecimal
+	tests := []struct {
+		name        string
+		rules       string
+		dividend    Transaction
+		gross, wht  Decimal
+		outOfPeriod bool
+	}{
+		{
+			name:     "LK grosses up the net amount",
+			rules:    "LK",
+			dividend: Transaction{Date: "2024-09-02", NetAmount: 850},
+			gross:    d("1000"),
+			wht:      d("150"),
+		},
+		{
+			name:     "recorded withholding wins",
+			rules:    "LK",
+			dividend: Transaction{Date: "2024-09-02", NetAmount: 850, TaxWithheld: 100},
+			gross:    d("950"),
+			wht:      d("100"),
+		},
+		{
+			name:     "no withholding rate",
+			rules:    "UK",
+			dividend: Transaction{Date: "2024-09-02", NetAmount: 850},
+			gross:    d("850"),
+		},
+		{
+			name:        "paid before the year",
+			rules:       "LK",
+			dividend:    Transaction{Date: "2024-03-29", NetAmount: 850},
+			outOfPeriod: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := tt.dividend
+			tx.ID, tx.Type, tx.Symbol = "d1", DIVIDEND, "JKH"
+			report := BuildTaxReport([]Transaction{tx}, mustTaxRules(t, tt.rules), 2024)
+
+			if tt.outOfPeriod {
+				if len(report.Dividends) != 0 {
+					t.Fatalf("dividends = %+v, want none", report.Dividends)
+				}
+				return
+			}
+			if len(report.Dividends) != 1 {
+				t.Fatalf("dividends = %+v, want one", report.Dividends)
+			}
+			got := report.Dividends[0]
+			if got.Gross != tt.gross || got.TaxWithheld != tt.wht || got.Net != NewDecimalFromFloat(tx.NetAmount) {
+				t.Errorf("dividend = gross %s wht %s net %s, want gross %s wht %s net %v",
+					got.Gross, got.TaxWithheld, got.Net, tt.gross, tt.wht, tx.NetAmount)
+			}
+			if report.Totals.DividendGross != tt.gross || report.Totals.DividendTaxWithheld != tt.wht {
+				t.Errorf("totals = gross %s wht %s, want gross %s wht %s",
+					report.Totals.DividendGross, report.Totals.DividendTaxWithheld, tt.gross, tt.wht)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleTaxReport serves GET /portfolio/tax-report.
+//
+// Rules come from the jurisdiction query param, falling back to the user's
+// taxJurisdiction setting and then LK. startMonth, costBasis, cgtRate and
+// whtRate override individual fields of the chosen rule set.
+func handleTaxReport(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing uid parameter"})
+		return
+	}
+
+	ctx := context.Background()
+	settings := fetchGeneralSettings(ctx, uid)
+
+	jurisdiction := c.Query("jurisdiction")
+	if jurisdiction == "" {
+		if v, ok := settings["taxJurisdiction"].(string); ok {
+			jurisdiction = v
+		}
+	}
+	rules, ok := LookupTaxRules(jurisdiction)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown jurisdiction %q", jurisdiction)})
+		return
+	}
+
+	if v := c.Query("startMonth"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 1 || m > 12 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "startMonth must be 1-12"})
+			return
+		}
+		rules.FiscalYearStartMonth = time.Month(m)
+	}
+	if v := c.Query("costBasis"); v != "" {
+		method := CostBasisMethod(strings.ToUpper(v))
+		if method != AverageCost && method != FIFO {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "costBasis must be AVERAGE or FIFO"})
+			return
+		}
+		rules.CostBasisMethod = method
+	}
+	for param, field := range map[string]*Decimal{
+		"cgtRate": &rules.CapitalGainsRate,
+		"whtRate": &rules.DividendWithholdingRate,
+	} {
+		if v := c.Query(param); v != "" {
+			rate, err := ParseDecimal(v)
+			if err != nil || rate < 0 || rate >= NewDecimalFromInt(1) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a fraction between 0 and 1"})
+				return
+			}
+			*field = rate
+		}
+	}
+
+	fiscalYear := FiscalYearFor(time.Now().In(colombo), rules.FiscalYearStartMonth)
+	if v := c.Query("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be the calendar year the fiscal year starts in"})
+			return
+		}
+		fiscalYear = y
+	}
+
+	transactions, err := fetchTransactions(ctx, uid)
+	if err != nil {
+		log.Printf("Error fetching transactions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	report := BuildTaxReport(transactions, rules, fiscalYear)
+
+	if strings.EqualFold(c.Query("format"), "csv") {
+		filename := fmt.Sprintf("tax-report-%s-%d.csv", strings.ToLower(rules.Jurisdiction), fiscalYear)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		if err := writeTaxReportCSV(c.Writer, report); err != nil {
+			log.Printf("Error writing tax report CSV: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// writeTaxReportCSV flattens the report into one table; the section column
+// distinguishes disposals, dividends and the closing totals.
+func writeTaxReportCSV(w io.Writer, report TaxReport) error {
+	cw := csv.NewWriter(w)
+	money := func(v Decimal) string { return v.StringFixed(currencyPlaces) }
+
+	rows := [][]string{
+		{"section", "date", "symbol", "transactionId", "qty", "proceeds", "costBasis", "gain", "gross", "taxWithheld", "net"},
+	}
+	for _, d := range report.Disposals {
+		rows = append(rows, []string{"DISPOSAL", d.Date, d.Symbol, d.TransactionID,
+			d.Qty.String(), money(d.Proceeds), money(d.CostBasis), money(d.Gain), "", "", ""})
+	}
+	for _, d := range report.Dividends {
+		rows = append(rows, []string{"DIVIDEND", d.Date, d.Symbol, d.TransactionID,
+			"", "", "", "", money(d.Gross), money(d.TaxWithheld), money(d.Net)})
+	}
+	t := report.Totals
+	rows = append(rows,
+		[]string{"TOTAL", report.PeriodEnd, "", "", "", money(t.Proceeds), money(t.CostBasis), money(t.RealisedGain),
+			money(t.DividendGross), money(t.DividendTaxWithheld), money(t.DividendNet)},
+		[]string{"ESTIMATED_CGT", report.PeriodEnd, "", "", "", "", "", money(t.EstimatedCapitalGainsTax), "", "", ""},
+	)
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -11,7 +11,9 @@ const (
 	DIVIDEND TransactionType = "DIVIDEND"
 )
 
-// Transaction represents a single user transaction
+// Transaction represents a single user transaction. Amounts keep the float
+// representation they are stored with in Firestore; the engine converts them
+// to Decimal before doing any arithmetic.
 type Transaction struct {
 	ID        string          `json:"id" firestore:"id"`
 	Date      string          `json:"date" firestore:"date"`
@@ -22,32 +24,94 @@ type Transaction struct {
 	Fee       float64         `json:"fee" firestore:"fee"`
 	NetAmount float64         `json:"netAmount" firestore:"netAmount"`
 	Notes     string          `json:"notes,omitempty" firestore:"notes,omitempty"`
+
+	// TaxWithheld is the WHT deducted at source on a DIVIDEND, when known
+	TaxWithheld float64 `json:"taxWithheld,omitempty" firestore:"taxWithheld,omitempty"`
 }
 
 // MarketData represents the latest price map
 type MarketData map[string]interface{} // Using interface{} to handle string/float mix if needed, or strictly defined
 
+// PriceStatus records where the price used to value a holding came from
+type PriceStatus string
+
+const (
+	PriceLive      PriceStatus = "live"
+	PriceLastKnown PriceStatus = "last-known"
+	PriceManual    PriceStatus = "manual"
+	PriceMissing   PriceStatus = "missing"
+)
+
+// PriceQuote is a price together with its provenance
+type PriceQuote struct {
+	Price         Decimal
+	PreviousClose Decimal // zero when unknown
+	Status        PriceStatus
+	AsOf          string // RFC3339, empty when unknown
+}
+
 // Holding represents a calculated stock holding
 type Holding struct {
-	Symbol         string  `json:"symbol"`
-	Qty            float64 `json:"qty"`
-	CurrentPrice   float64 `json:"currentPrice"`
-	MarketValue    float64 `json:"marketValue"`
-	LifecycleGain  float64 `json:"lifecycleGain"`
-	Allocation     float64 `json:"allocation"`
+	Symbol         string      `json:"symbol"`
+	Qty            Decimal     `json:"qty"`
+	CurrentPrice   Decimal     `json:"currentPrice"`
+	PriceStatus    PriceStatus `json:"priceStatus"`
+	PriceTimestamp string      `json:"priceTimestamp,omitempty"`
+	MarketValue    Decimal     `json:"marketValue"`
+	LifecycleGain  Decimal     `json:"lifecycleGain"`
+	Allocation     Decimal     `json:"allocation"`
+
+	// DayChange is the move in MarketValue since the previous close. Both are
+	// zero unless the price is live and a previous close is known.
+	DayChange    Decimal `json:"dayChange"`
+	DayChangePct Decimal `json:"dayChangePct"`
 }
 
 // PortfolioSummary represents the final dashboard state
 type PortfolioSummary struct {
-	NetWorth           float64   `json:"netWorth"`
-	NetInvested        float64   `json:"netInvested"`
-	CashOnHand         float64   `json:"cashOnHand"`
-	TotalLifecycleGain float64   `json:"totalLifecycleGain"`
-	Holdings           []Holding `json:"holdings"`
-	AssetAllocation    []Asset   `json:"assetAllocation"`
+	NetWorth           Decimal      `json:"netWorth"`
+	NetInvested        Decimal      `json:"netInvested"`
+	CashOnHand         Decimal      `json:"cashOnHand"`
+	TotalLifecycleGain Decimal      `json:"totalLifecycleGain"`
+	Holdings           []Holding    `json:"holdings"`
+	AssetAllocation    []Asset      `json:"assetAllocation"`
+	Diagnostics        []Diagnostic `json:"diagnostics,omitempty"`
+
+	// DayChangePct is measured against yesterday's value of the holdings
+	// that have a previous close, not against NetWorth.
+	DayChange    Decimal `json:"dayChange"`
+	DayChangePct Decimal `json:"dayChangePct"`
+
+	// ValuationIncomplete is set when any open holding has no price at all;
+	// MissingPrices lists those symbols. NetWorth then understates reality.
+	ValuationIncomplete bool     `json:"valuationIncomplete"`
+	MissingPrices       []string `json:"missingPrices,omitempty"`
+
+	// MarketData says how fresh the prices behind this summary are
+	MarketData *MarketStatus `json:"marketData,omitempty"`
 }
 
 type Asset struct {
 	Name  string  `json:"name"`
-	Value float64 `json:"value"`
+	Value Decimal `json:"value"`
+}
+
+// DiagnosticCode identifies a chronologically impossible or suspicious state
+type DiagnosticCode string
+
+const (
+	DiagOversell        DiagnosticCode = "OVERSELL"
+	DiagNegativeCash    DiagnosticCode = "NEGATIVE_CASH"
+	DiagDividendNotHeld DiagnosticCode = "DIVIDEND_NOT_HELD"
+	DiagSignConvention  DiagnosticCode = "SIGN_CONVENTION"
+	DiagUnparseableDate DiagnosticCode = "UNPARSEABLE_DATE"
+)
+
+// Diagnostic points at the transaction that produced an impossible state
+type Diagnostic struct {
+	Code          DiagnosticCode `json:"code"`
+	TransactionID string         `json:"transactionId"`
+	Date          string         `json:"date"`
+	Symbol        string         `json:"symbol,omitempty"`
+	Message       string         `json:"message"`
 }
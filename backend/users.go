@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+)
+
+// fetchActiveUsers returns the uids that have at least one transaction,
+// sorted. users/{uid} itself is usually never written (only its
+// subcollections are), so this lists document references, which include
+// such missing parents, rather than querying the collection.
+func fetchActiveUsers(ctx context.Context) ([]string, error) {
+	refs, err := client.Collection("users").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	uids := []string{}
+	for _, ref := range refs {
+		iter := ref.Collection("transactions").Limit(1).Documents(ctx)
+		_, err := iter.Next()
+		iter.Stop()
+		if err == iterator.Done {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		uids = append(uids, ref.ID)
+	}
+	sort.Strings(uids)
+	return uids, nil
+}
+
+// handleListUsers serves GET /admin/users: the users the task snapshots
+func handleListUsers(c *gin.Context) {
+	uids, err := fetchActiveUsers(context.Background())
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": uids, "count": len(uids)})
+}
@@ -72,6 +72,14 @@ export default function Dashboard() {
                     </div>
                 </header>
 
+                {data?.marketData?.stale && (
+                    <div className="mb-8 rounded-xl border border-amber-500/30 bg-amber-500/10 px-4 py-3 text-sm text-amber-300">
+                        {data.marketData.updatedAt
+                            ? `Prices were last updated ${new Date(data.marketData.updatedAt).toLocaleString()} and have missed the latest market close. Values may be out of date.`
+                            : 'No market prices have been loaded yet. Holdings are not valued.'}
+                    </div>
+                )}
+
                 {/* Metrics Grid */}
                 <div className="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-6 mb-8">
                     <MetricCard
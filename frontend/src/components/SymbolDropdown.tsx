@@ -3,6 +3,7 @@
 import { useEffect, useState } from 'react';
 import { clsx } from 'clsx';
 import { Search } from 'lucide-react';
+import { SymbolInfo } from '@/lib/types';
 
 interface SymbolDropdownProps {
     value: string;
@@ -10,32 +11,42 @@ interface SymbolDropdownProps {
 }
 
 export function SymbolDropdown({ value, onChange }: SymbolDropdownProps) {
-    const [symbols, setSymbols] = useState<string[]>([]);
+    const [symbols, setSymbols] = useState<SymbolInfo[]>([]);
     const [isOpen, setIsOpen] = useState(false);
     const [search, setSearch] = useState('');
     const [loading, setLoading] = useState(false);
 
+    // Matching (symbol or company name, typo tolerant) happens on the backend
     useEffect(() => {
-        const fetchSymbols = async () => {
+        if (!isOpen) return;
+
+        const controller = new AbortController();
+        const timer = setTimeout(async () => {
             setLoading(true);
             try {
                 const backendUrl = process.env.NEXT_PUBLIC_BACKEND_URL || 'http://localhost:8080';
-                const res = await fetch(`${backendUrl}/market/symbols`);
+                const res = await fetch(
+                    `${backendUrl}/market/symbols/search?q=${encodeURIComponent(search)}&limit=50`,
+                    { signal: controller.signal }
+                );
                 if (res.ok) {
                     const data = await res.json();
                     setSymbols(data);
                 }
             } catch (error) {
-                console.error("Error fetching symbols:", error);
+                if ((error as Error).name !== 'AbortError') {
+                    console.error("Error fetching symbols:", error);
+                }
             } finally {
                 setLoading(false);
             }
-        };
+        }, 200);
 
-        fetchSymbols();
-    }, []);
-
-    const filteredSymbols = symbols.filter(s => s.toLowerCase().includes(search.toLowerCase()));
+        return () => {
+            clearTimeout(timer);
+            controller.abort();
+        };
+    }, [isOpen, search]);
 
     return (
         <div className="relative">
@@ -69,23 +80,26 @@ export function SymbolDropdown({ value, onChange }: SymbolDropdownProps) {
                     <div className="overflow-y-auto flex-1">
                         {loading ? (
                             <div className="p-4 text-center text-xs text-slate-500">Loading...</div>
-                        ) : filteredSymbols.length === 0 ? (
+                        ) : symbols.length === 0 ? (
                             <div className="p-4 text-center text-xs text-slate-500">No matches found</div>
                         ) : (
-                            filteredSymbols.map(sym => (
+                            symbols.map(info => (
                                 <div
-                                    key={sym}
+                                    key={info.symbol}
                                     className={clsx(
                                         "px-4 py-2 text-sm cursor-pointer hover:bg-slate-800 transition-colors",
-                                        value === sym ? "text-blue-400 bg-blue-900/20" : "text-slate-300"
+                                        value === info.symbol ? "text-blue-400 bg-blue-900/20" : "text-slate-300"
                                     )}
                                     onClick={() => {
-                                        onChange(sym);
+                                        onChange(info.symbol);
                                         setIsOpen(false);
                                         setSearch('');
                                     }}
                                 >
-                                    {sym}
+                                    <div>{info.symbol}</div>
+                                    {info.name && (
+                                        <div className="text-xs text-slate-500 truncate">{info.name}</div>
+                                    )}
                                 </div>
                             ))
                         )}
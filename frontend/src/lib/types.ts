@@ -37,4 +37,23 @@ export interface PortfolioSummary {
     totalLifecycleGain: number;
     holdings: Holding[];
     assetAllocation: { name: string; value: number }[];
+    marketData?: MarketStatus;
+}
+
+export interface MarketStatus {
+    updatedAt?: string;
+    source?: string;
+    symbolCount: number;
+    ageSeconds: number;
+    lastClose: string;
+    stale: boolean;
+}
+
+export interface SymbolInfo {
+    symbol: string;
+    name?: string;
+    board?: string;
+    sector?: string;
+    status: 'listed' | 'suspended' | 'delisted' | 'unlisted';
+    lastSeen?: string;
 }
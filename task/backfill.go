@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BackfillResult is the backend's POST /admin/backfill-history report
+type BackfillResult struct {
+	UID        string   `json:"uid"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Mode       string   `json:"mode"`
+	DryRun     bool     `json:"dryRun"`
+	Written    []string `json:"written"`
+	Replaced   []string `json:"replaced"`
+	Skipped    []string `json:"skipped"`
+	Incomplete []string `json:"incomplete"`
+}
+
+// backfillUser asks the backend to regenerate a user's daily snapshots for
+// from..to (YYYY-MM-DD) from transactions and stored prices. mode is skip
+// or replace, for dates that already have a snapshot.
+func backfillUser(ctx context.Context, httpClient *HTTPClient, backendURL, uid, from, to, mode string, dryRun bool) (BackfillResult, error) {
+	q := url.Values{"uid": {uid}, "from": {from}, "to": {to}, "mode": {mode}}
+	if dryRun {
+		q.Set("dryRun", "true")
+	}
+	resp, err := httpClient.Do(ctx, "POST", backendURL+"/admin/backfill-history?"+q.Encode(), nil, nil)
+	if err != nil {
+		return BackfillResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return BackfillResult{}, fmt.Errorf("%d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result BackfillResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return BackfillResult{}, fmt.Errorf("decoding backfill report: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// TradingDay is the part of the backend's GET /market/calendar response the
+// task acts on. The holiday list lives in the backend so both sides agree.
+type TradingDay struct {
+	Date       string `json:"date"`
+	TradingDay bool   `json:"tradingDay"`
+	Reason     string `json:"reason"`
+	Phase      string `json:"phase"`
+}
+
+// fetchTradingDay asks the backend whether the exchange trades today
+func fetchTradingDay(ctx context.Context, httpClient *HTTPClient, backendURL string) (TradingDay, error) {
+	resp, err := httpClient.Do(ctx, "GET", backendURL+"/market/calendar", nil, nil)
+	if err != nil {
+		return TradingDay{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return TradingDay{}, fmt.Errorf("calendar returned %d", resp.StatusCode)
+	}
+
+	var day TradingDay
+	if err := json.NewDecoder(resp.Body).Decode(&day); err != nil {
+		return TradingDay{}, fmt.Errorf("decoding calendar: %w", err)
+	}
+	return day, nil
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// cli is what every subcommand shares: the loaded configuration and the
+// HTTP client built from it
+type cli struct {
+	Config     Config
+	HTTP       *HTTPClient
+	BackendURL string
+	Pool       SnapshotPool
+}
+
+// command is one subcommand of the task binary
+type command struct {
+	name    string
+	args    string // synopsis after the flags, e.g. "FILE"
+	summary string
+	flags   func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) int
+}
+
+var commands = []command{
+	{"run", "", "Scrape prices, then snapshot every user (the default, as scheduled)", runFlags},
+	{"daemon", "", "Run on schedule.cron until SIGTERM, with a status server on schedule.statusAddr", daemonFlags},
+	{"scrape", "", "Fetch prices from the configured sources and store them, without snapshots", scrapeFlags},
+	{"import-prices", "FILE", "Store prices from a JSON or CSV file, e.g. a manual close sheet", importFlags},
+	{"snapshot", "", "Snapshot users' portfolios from the prices already stored", snapshotFlags},
+	{"backfill", "", "Regenerate users' daily history from transactions and stored prices", backfillFlags},
+	{"status", "", "Show the trading calendar, market data freshness, recent runs and scheduler state", statusFlags},
+	{"config", "", "Validate the configuration and print it with environment overrides applied", configFlags},
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: task <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "task help <command>" or "task <command> -h" for its flags.`)
+	fmt.Fprintln(w, "Every command takes -config FILE; see task.example.yaml.")
+	fmt.Fprintln(w, "With no command, task runs the full pipeline once, as \"task run\".")
+}
+
+// splitCommand picks the subcommand out of the arguments. The flags of
+// earlier versions (-daemon, -dry-run, -dry-run-out) still work without one.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "run", nil
+	}
+	if !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	switch args[0] {
+	case "-h", "-help", "--help":
+		return "help", nil
+	}
+	rest := make([]string, 0, len(args))
+	name := "run"
+	for _, a := range args {
+		if a == "-daemon" || a == "--daemon" {
+			name = "daemon"
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return name, rest
+}
+
+// parseCommand parses a subcommand's flags. Flags may follow positional
+// arguments, so "import-prices close.csv -dry-run" works as expected.
+func parseCommand(cmd *command, args []string) (func(context.Context, *cli, []string) int, []string, *flag.FlagSet, error) {
+	fs := flag.NewFlagSet("task "+cmd.name, flag.ContinueOnError)
+	fs.String("config", "", "YAML config file (default $TASK_CONFIG, else ./task.yaml if present)")
+	run := cmd.flags(fs)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: task %s [flags] %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+		var hasFlags bool
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(w, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	return run, positional, fs, nil
+}
+
+// applyConfigDefaults gives the flags the config file has a say in their
+// configured value, unless they were set on the command line. Only the
+// price commands' -dry-run (the ones with -dry-run-out) follows dryRun;
+// the per-user commands' -dry-run is a preview and always opt-in.
+func applyConfigDefaults(fs *flag.FlagSet, cfg Config) error {
+	defaults := map[string]string{"force": strconv.FormatBool(cfg.Schedule.Force)}
+	if fs.Lookup("dry-run-out") != nil {
+		defaults["dry-run"] = strconv.FormatBool(cfg.DryRun.Enabled)
+		defaults["dry-run-out"] = cfg.DryRun.Out
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, value := range defaults {
+		if fs.Lookup(name) == nil || set[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// selectUsers resolves the -uid / -all pair every per-user command takes
+func (c *cli) selectUsers(ctx context.Context, uids stringList, all bool) ([]string, int) {
+	switch {
+	case all && len(uids) > 0:
+		log.Println("Give either -uid or -all, not both.")
+		return nil, exitConfig
+	case all:
+		found, err := snapshotUIDs(ctx, c.HTTP, c.BackendURL, c.Config.Users.UIDs)
+		if err != nil {
+			log.Printf("Error finding users: %v", err)
+			return nil, exitBackendFailure
+		}
+		return found, exitOK
+	case len(uids) == 0:
+		log.Println("Give -uid (repeatable) or -all.")
+		return nil, exitConfig
+	}
+	return uids, exitOK
+}
+
+// batchExitCode maps per-user outcomes to an exit code
+func batchExitCode(failed, total int) int {
+	switch {
+	case failed == 0:
+		return exitOK
+	case failed == total:
+		return exitBackendFailure
+	}
+	return exitPartial
+}
+
+func (c *cli) pipeline(command string, force, dryRun bool, dryRunOut string) *Pipeline {
+	return &Pipeline{
+		HTTP:       c.HTTP,
+		BackendURL: c.BackendURL,
+		Sources:    c.Config.Sources,
+		UIDs:       c.Config.Users.UIDs,
+		Pool:       c.Pool,
+		Force:      force,
+		DryRun:     dryRun,
+		DryRunOut:  dryRunOut,
+		Command:    command,
+		Trigger:    "cli",
+	}
+}
+
+// forceFlag is shared by the commands that check the trading calendar.
+// Forcing runs even on weekends and holidays, e.g. for a late backfill.
+func forceFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("force", false, "run even on weekends and exchange holidays (default schedule.force)")
+}
+
+// dryRunFlags are shared by the commands that fetch and store prices
+func dryRunFlags(fs *flag.FlagSet) (dryRun *bool, dryRunOut *string) {
+	dryRun = fs.Bool("dry-run", false, "fetch and transform prices and report what would be written, without writing (default dryRun.enabled)")
+	dryRunOut = fs.String("dry-run-out", "", "write the dry-run report to this file instead of stdout (default dryRun.out)")
+	return
+}
+
+func finish(result RunResult) int {
+	if result.ExitCode == exitOK {
+		log.Println("Task completed.")
+	}
+	return result.ExitCode
+}
+
+func runFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	force := forceFlag(fs)
+	dryRun, dryRunOut := dryRunFlags(fs)
+	return func(ctx context.Context, c *cli, _ []string) int {
+		return finish(c.pipeline("run", *force, *dryRun, *dryRunOut).Run(ctx))
+	}
+}
+
+func scrapeFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	force := forceFlag(fs)
+	dryRun, dryRunOut := dryRunFlags(fs)
+	return func(ctx context.Context, c *cli, _ []string) int {
+		p := c.pipeline("scrape", *force, *dryRun, *dryRunOut)
+		p.NoSnapshots = true
+		return finish(p.Run(ctx))
+	}
+}
+
+func importFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	dryRun, dryRunOut := dryRunFlags(fs)
+	snapshot := fs.Bool("snapshot", false, "snapshot every user once the prices are stored")
+	return func(ctx context.Context, c *cli, args []string) int {
+		if len(args) != 1 {
+			log.Println("import-prices takes exactly one FILE.")
+			return exitConfig
+		}
+		// An explicit import is never held back by the calendar
+		p := c.pipeline("import-prices", true, *dryRun, *dryRunOut)
+		p.Provider = &FileProvider{Path: args[0]}
+		p.NoSnapshots = !*snapshot
+		return finish(p.Run(ctx))
+	}
+}
+
+func daemonFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	return func(ctx context.Context, c *cli, _ []string) int {
+		d, err := newDaemon(c.pipeline("run", c.Config.Schedule.Force, false, ""), c.Config.Schedule)
+		if err != nil {
+			log.Printf("Error configuring scheduler: %v", err)
+			return exitConfig
+		}
+		log.Printf("Scheduler mode: %q in %s", d.Schedule.Expr, d.Schedule.Location)
+		return d.Serve(ctx)
+	}
+}
+
+func snapshotFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	var uids stringList
+	fs.Var(&uids, "uid", "user to snapshot; repeat or comma-separate for several")
+	all := fs.Bool("all", false, "snapshot every active user (or users.uids when set)")
+	dryRun := fs.Bool("dry-run", false, "print the snapshots that would be saved, without saving")
+	return func(ctx context.Context, c *cli, _ []string) int {
+		users, code := c.selectUsers(ctx, uids, *all)
+		if code != exitOK {
+			return code
+		}
+
+		if *dryRun {
+			previews, results := previewAll(ctx, c.HTTP, c.BackendURL, users, nil, c.Pool)
+			failed, ignored := 0, false
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+					ignored = ignored || errors.Is(r.Err, errDryRunIgnored)
+					log.Printf("  snapshot %s: FAILED: %v", r.UID, r.Err)
+				}
+			}
+			if err := printJSON(previews); err != nil {
+				log.Printf("Error writing snapshots: %v", err)
+				return exitConfig
+			}
+			if ignored {
+				return exitBackendFailure
+			}
+			return batchExitCode(failed, len(users))
+		}
+
+		result := RunResult{Command: "snapshot", Trigger: "cli", Start: time.Now()}
+		for _, r := range snapshotAll(ctx, c.HTTP, c.BackendURL, users, c.Pool) {
+			if r.Err != nil {
+				result.snapshotFailed(r.UID, r.Err)
+				continue
+			}
+			result.Snapshots++
+			log.Printf("  snapshot %s: ok", r.UID)
+		}
+		log.Printf("%d of %d snapshots saved.", result.Snapshots, len(users))
+		result.ExitCode = batchExitCode(result.Failed, len(users))
+		result.End = time.Now()
+		recordRun(ctx, c.HTTP, c.BackendURL, result)
+		return result.ExitCode
+	}
+}
+
+func backfillFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	var uids stringList
+	fs.Var(&uids, "uid", "user to backfill; repeat or comma-separate for several")
+	all := fs.Bool("all", false, "backfill every active user (or users.uids when set)")
+	from := fs.String("from", "", "first date, YYYY-MM-DD (default 30 days before -to)")
+	to := fs.String("to", "", "last date, YYYY-MM-DD (default today)")
+	mode := fs.String("mode", "skip", "dates that already have a snapshot: skip or replace")
+	dryRun := fs.Bool("dry-run", false, "report which dates would be written, without writing")
+	return func(ctx context.Context, c *cli, _ []string) int {
+		if *mode != "skip" && *mode != "replace" {
+			log.Printf("-mode %q: want skip or replace", *mode)
+			return exitConfig
+		}
+		users, code := c.selectUsers(ctx, uids, *all)
+		if code != exitOK {
+			return code
+		}
+
+		format := "  backfill %s %s..%s: %d written, %d replaced, %d kept."
+		if *dryRun {
+			format = "  backfill %s %s..%s: would write %d, replace %d, keep %d."
+		}
+		// Snapshots counts the days written or replaced across all users
+		result := RunResult{Command: "backfill", Trigger: "cli", Start: time.Now()}
+		for _, uid := range users {
+			r, err := backfillUser(ctx, c.HTTP, c.BackendURL, uid, *from, *to, *mode, *dryRun)
+			if err != nil {
+				result.userFailed("backfill", uid, err)
+				continue
+			}
+			result.Snapshots += len(r.Written) + len(r.Replaced)
+			log.Printf(format, uid, r.From, r.To, len(r.Written), len(r.Replaced), len(r.Skipped))
+			if len(r.Incomplete) > 0 {
+				log.Printf("    missing prices on %s", strings.Join(r.Incomplete, ", "))
+			}
+		}
+		result.ExitCode = batchExitCode(result.Failed, len(users))
+		result.End = time.Now()
+		if !*dryRun {
+			recordRun(ctx, c.HTTP, c.BackendURL, result)
+		}
+		return result.ExitCode
+	}
+}
+
+func statusFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	scheduler := fs.String("scheduler", "", "base URL of a running daemon's status server, e.g. http://localhost:8081")
+	return func(ctx context.Context, c *cli, _ []string) int {
+		status := map[string]interface{}{}
+		code := exitOK
+		for key, u := range map[string]string{
+			"calendar":   c.BackendURL + "/market/calendar",
+			"marketData": c.BackendURL + "/market/status",
+			"recentRuns": c.BackendURL + "/admin/task-runs?limit=5",
+			"scheduler":  strings.TrimRight(*scheduler, "/") + "/status",
+		} {
+			if key == "scheduler" && *scheduler == "" {
+				continue
+			}
+			body, err := getJSON(ctx, c.HTTP, u)
+			if err != nil {
+				log.Printf("Error fetching %s: %v", key, err)
+				status[key] = map[string]string{"error": err.Error()}
+				code = exitBackendFailure
+				continue
+			}
+			status[key] = body
+		}
+		if err := printJSON(status); err != nil {
+			log.Printf("Error writing status: %v", err)
+			return exitConfig
+		}
+		return code
+	}
+}
+
+func configFlags(fs *flag.FlagSet) func(context.Context, *cli, []string) int {
+	return func(_ context.Context, c *cli, _ []string) int {
+		out, err := yaml.Marshal(c.Config.redacted())
+		if err != nil {
+			log.Printf("Error encoding config: %v", err)
+			return exitConfig
+		}
+		os.Stdout.Write(out)
+		return exitOK
+	}
+}
+
+// getJSON fetches a JSON document
+func getJSON(ctx context.Context, httpClient *HTTPClient, u string) (json.RawMessage, error) {
+	resp, err := httpClient.Do(ctx, "GET", u, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.RawMessage(body), nil
+}
+
+// printJSON writes v to stdout, indented, with map keys sorted
+func printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(out, '\n'))
+	return err
+}
+
+// runCLI loads the configuration, dispatches to a subcommand and returns
+// the process exit code
+func runCLI(ctx context.Context, args []string) int {
+	name, rest := splitCommand(args)
+	if name == "help" {
+		if len(rest) > 0 {
+			if cmd := findCommand(rest[0]); cmd != nil {
+				_, _, _, err := parseCommand(cmd, []string{"-h"})
+				if errors.Is(err, flag.ErrHelp) {
+					return exitOK
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", rest[0])
+			usage(os.Stderr)
+			return exitConfig
+		}
+		usage(os.Stdout)
+		return exitOK
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", name)
+		usage(os.Stderr)
+		return exitConfig
+	}
+	run, positional, fs, err := parseCommand(cmd, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitConfig
+	}
+
+	cfg, err := loadConfig(configPath(fs.Lookup("config").Value.String()))
+	if err != nil {
+		log.Print(err)
+		return exitConfig
+	}
+	if err := applyConfigDefaults(fs, cfg); err != nil {
+		log.Printf("Error applying config defaults: %v", err)
+		return exitConfig
+	}
+	httpClient := newHTTPClient(cfg.HTTP)
+	httpClient.Signer = newServiceSigner(cfg.Backend)
+	c := &cli{
+		Config:     cfg,
+		HTTP:       httpClient,
+		BackendURL: cfg.Backend.URL,
+		Pool:       SnapshotPool{Concurrency: cfg.Users.Concurrency, Timeout: time.Duration(cfg.Users.Timeout)},
+	}
+	return run(ctx, c, positional)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Config is everything the task can be told. It is built from defaults,
+// then the YAML file, then environment variables, each overriding the
+// last; the env tag names the variable for each field. See
+// task.example.yaml for a commented file.
+type Config struct {
+	Backend  BackendConfig  `yaml:"backend"`
+	Sources  []SourceConfig `yaml:"sources"` // in order of precedence
+	Users    UsersConfig    `yaml:"users"`
+	Schedule ScheduleConfig `yaml:"schedule"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	DryRun   DryRunConfig   `yaml:"dryRun"`
+}
+
+type BackendConfig struct {
+	URL              string `yaml:"url" env:"NEXT_PUBLIC_BACKEND_URL"`
+	ServiceKeyID     string `yaml:"serviceKeyId" env:"SERVICE_KEY_ID"`
+	ServiceKeySecret string `yaml:"serviceKeySecret" env:"SERVICE_KEY_SECRET"`
+}
+
+// SourceConfig is one price source. PRICE_PROVIDER (comma-separated types)
+// replaces the whole list; CSE_BASE_URL, CSE_RECORD_FIXTURE, PRICE_FILE and
+// PRICE_FIXTURE set the matching field on every source of their type.
+type SourceConfig struct {
+	Type          string `yaml:"type"`                    // cse, file or fixture
+	BaseURL       string `yaml:"baseURL,omitempty"`       // cse: overrides the host
+	RecordFixture string `yaml:"recordFixture,omitempty"` // cse: save each raw response here
+	Path          string `yaml:"path,omitempty"`          // file: JSON or CSV prices; fixture: recorded response
+}
+
+type UsersConfig struct {
+	UIDs        []string `yaml:"uids" env:"SNAPSHOT_UIDS"` // empty means every active user
+	Concurrency int      `yaml:"concurrency" env:"SNAPSHOT_CONCURRENCY"`
+	Timeout     Duration `yaml:"timeout" env:"SNAPSHOT_TIMEOUT"` // per user, retries included
+}
+
+type ScheduleConfig struct {
+	Cron          string   `yaml:"cron" env:"TASK_SCHEDULE"`                // in Asia/Colombo
+	StatusAddr    string   `yaml:"statusAddr" env:"TASK_STATUS_ADDR"`       // loopback by default; "off" disables the status server
+	ShutdownGrace Duration `yaml:"shutdownGrace" env:"TASK_SHUTDOWN_GRACE"` // time an in-flight run gets on SIGTERM
+	Force         bool     `yaml:"force" env:"FORCE_RUN"`                   // run on weekends and holidays too
+}
+
+type HTTPConfig struct {
+	Timeout     Duration `yaml:"timeout" env:"HTTP_TIMEOUT"` // per attempt
+	MaxAttempts int      `yaml:"maxAttempts" env:"HTTP_MAX_ATTEMPTS"`
+	BackoffBase Duration `yaml:"backoffBase" env:"HTTP_BACKOFF_BASE"`
+	BackoffMax  Duration `yaml:"backoffMax" env:"HTTP_BACKOFF_MAX"`
+}
+
+// DryRunConfig sets the defaults of the -dry-run and -dry-run-out flags
+type DryRunConfig struct {
+	Enabled bool   `yaml:"enabled" env:"DRY_RUN"`
+	Out     string `yaml:"out" env:"DRY_RUN_OUT"`
+}
+
+// Duration reads and writes as a Go duration string such as "90s"
+type Duration time.Duration
+
+// UnmarshalYAML reports a bad value as a syntax error so it is shown at
+// its place in the file
+func (d *Duration) UnmarshalYAML(node ast.Node) error {
+	s, ok := node.(*ast.StringNode)
+	if !ok {
+		return &yaml.SyntaxError{Message: "want a duration such as 30s or 2m", Token: node.GetToken()}
+	}
+	v, err := time.ParseDuration(s.Value)
+	if err != nil {
+		return &yaml.SyntaxError{Message: fmt.Sprintf("want a duration such as 30s or 2m, got %q", s.Value), Token: node.GetToken()}
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) { return time.Duration(d).String(), nil }
+
+func defaultConfig() Config {
+	return Config{
+		Backend: BackendConfig{URL: "http://localhost:8080"},
+		Sources: []SourceConfig{{Type: "cse"}},
+		Users:   UsersConfig{Concurrency: 4, Timeout: Duration(2 * time.Minute)},
+		Schedule: ScheduleConfig{
+			Cron:          defaultSchedule,
+			StatusAddr:    "127.0.0.1:8081",
+			ShutdownGrace: Duration(2 * time.Minute),
+		},
+		HTTP: HTTPConfig{
+			Timeout:     Duration(30 * time.Second),
+			MaxAttempts: 4,
+			BackoffBase: Duration(500 * time.Millisecond),
+			BackoffMax:  Duration(30 * time.Second),
+		},
+	}
+}
+
+// configPath picks the file to load: the -config flag, else TASK_CONFIG,
+// else task.yaml in the working directory if there is one
+func configPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := os.Getenv("TASK_CONFIG"); v != "" {
+		return v
+	}
+	if _, err := os.Stat("task.yaml"); err == nil {
+		return "task.yaml"
+	}
+	return ""
+}
+
+// loadConfig builds and validates the configuration. path may be empty.
+// The error lists every problem found, not just the first.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config: %w", err)
+		}
+		if err := yaml.UnmarshalWithOptions(data, &cfg, yaml.Strict()); err != nil {
+			return cfg, fmt.Errorf("config %s:\n%s", path, yaml.FormatError(err, false, true))
+		}
+	}
+
+	var problems []string
+	problems = append(problems, applyEnv(reflect.ValueOf(&cfg).Elem(), "")...)
+	applySourceEnv(&cfg)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		where := "configuration"
+		if path != "" {
+			where = path
+		}
+		return cfg, fmt.Errorf("invalid %s:\n  - %s", where, strings.Join(problems, "\n  - "))
+	}
+	return cfg, nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// applyEnv overrides every field with an env tag whose variable is set
+func applyEnv(v reflect.Value, prefix string) []string {
+	var problems []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		name := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			problems = append(problems, applyEnv(value, name+".")...)
+			continue
+		}
+		env := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(env)
+		if env == "" || !ok || raw == "" {
+			continue
+		}
+
+		bad := func(want string) {
+			problems = append(problems, fmt.Sprintf("%s: %s=%q: want %s", name, env, raw, want))
+		}
+		switch {
+		case field.Type == durationType:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				bad("a duration such as 30s")
+				continue
+			}
+			value.SetInt(int64(d))
+		case field.Type.Kind() == reflect.String:
+			value.SetString(raw)
+		case field.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				bad("true or false")
+				continue
+			}
+			value.SetBool(b)
+		case field.Type.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				bad("an integer")
+				continue
+			}
+			value.SetInt(int64(n))
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			var items []string
+			for _, s := range strings.Split(raw, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					items = append(items, s)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+		}
+	}
+	return problems
+}
+
+// applySourceEnv applies the price source variables, which predate the
+// config file and do not map onto a single field
+func applySourceEnv(cfg *Config) {
+	if v := os.Getenv("PRICE_PROVIDER"); v != "" {
+		// Keep what the file says about a source type that is still listed
+		configured := make(map[string]SourceConfig)
+		for _, src := range cfg.Sources {
+			if _, ok := configured[src.Type]; !ok {
+				configured[src.Type] = src
+			}
+		}
+		cfg.Sources = nil
+		for _, kind := range strings.Split(v, ",") {
+			if kind = strings.ToLower(strings.TrimSpace(kind)); kind != "" {
+				src, ok := configured[kind]
+				if !ok {
+					src = SourceConfig{Type: kind}
+				}
+				cfg.Sources = append(cfg.Sources, src)
+			}
+		}
+	}
+	for i := range cfg.Sources {
+		src := &cfg.Sources[i]
+		set := func(dst *string, env string) {
+			if v := os.Getenv(env); v != "" {
+				*dst = v
+			}
+		}
+		switch src.Type {
+		case "cse":
+			set(&src.BaseURL, "CSE_BASE_URL")
+			set(&src.RecordFixture, "CSE_RECORD_FIXTURE")
+		case "file":
+			set(&src.Path, "PRICE_FILE")
+		case "fixture":
+			set(&src.Path, "PRICE_FIXTURE")
+		}
+	}
+}
+
+// validate reports every invalid field, named by its path in the file
+func (cfg *Config) validate() []string {
+	var problems []string
+	bad := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(cfg.Backend.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		bad("backend.url: %q is not an http(s) URL", cfg.Backend.URL)
+	}
+	if (cfg.Backend.ServiceKeyID == "") != (cfg.Backend.ServiceKeySecret == "") {
+		bad("backend.serviceKeyId and backend.serviceKeySecret must be set together")
+	}
+
+	if len(cfg.Sources) == 0 {
+		bad("sources: at least one price source is required")
+	}
+	for i, src := range cfg.Sources {
+		switch src.Type {
+		case "cse":
+			if src.BaseURL != "" {
+				if u, err := url.Parse(src.BaseURL); err != nil || u.Host == "" {
+					bad("sources[%d].baseURL: %q is not a URL", i, src.BaseURL)
+				}
+			}
+		case "file", "fixture":
+			if src.Path == "" {
+				bad("sources[%d]: a %s source needs path", i, src.Type)
+			}
+		default:
+			bad("sources[%d].type: %q is not cse, file or fixture", i, src.Type)
+		}
+	}
+
+	if cfg.Users.Concurrency < 1 {
+		bad("users.concurrency: must be at least 1, got %d", cfg.Users.Concurrency)
+	}
+	if cfg.Users.Timeout <= 0 {
+		bad("users.timeout: must be positive")
+	}
+
+	if _, err := ParseSchedule(cfg.Schedule.Cron, colombo); err != nil {
+		bad("schedule.cron: %v", err)
+	}
+	if cfg.Schedule.ShutdownGrace < 0 {
+		bad("schedule.shutdownGrace: must not be negative")
+	}
+
+	if cfg.HTTP.Timeout <= 0 {
+		bad("http.timeout: must be positive")
+	}
+	if cfg.HTTP.MaxAttempts < 1 {
+		bad("http.maxAttempts: must be at least 1, got %d", cfg.HTTP.MaxAttempts)
+	}
+	if cfg.HTTP.BackoffBase <= 0 {
+		bad("http.backoffBase: must be positive")
+	}
+	if cfg.HTTP.BackoffMax < cfg.HTTP.BackoffBase {
+		bad("http.backoffMax: must be at least http.backoffBase")
+	}
+	return problems
+}
+
+// redacted is the config as safe to print
+func (cfg Config) redacted() Config {
+	if cfg.Backend.ServiceKeySecret != "" {
+		cfg.Backend.ServiceKeySecret = "(redacted)"
+	}
+	return cfg
+}
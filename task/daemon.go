@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// colombo is the exchange timezone schedules are evaluated in. Sri Lanka
+// has no DST, so a fixed offset is an exact fallback when tzdata is missing.
+var colombo = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Colombo"); err == nil {
+		return loc
+	}
+	return time.FixedZone("+0530", 5*60*60+30*60)
+}()
+
+// Daemon runs the pipeline on a schedule and reports on it over HTTP.
+// Only one run is ever in flight: a tick or manual trigger that arrives
+// while a run is going is skipped, not queued.
+type Daemon struct {
+	Pipeline      *Pipeline
+	Schedule      *Schedule
+	StatusAddr    string        // empty disables the status server; POST /run is unauthenticated
+	ShutdownGrace time.Duration // how long an in-flight run may finish after SIGTERM
+
+	mu       sync.Mutex
+	running  bool
+	stopping bool
+	started  time.Time
+	last     *RunResult
+	next     time.Time
+	skipped  int // runs skipped because one was already in flight
+	wg       sync.WaitGroup
+}
+
+// DaemonStatus is the body of GET /status
+type DaemonStatus struct {
+	Schedule       string     `json:"schedule"`
+	Timezone       string     `json:"timezone"`
+	Running        bool       `json:"running"`
+	RunningSince   *time.Time `json:"runningSince,omitempty"`
+	NextRun        time.Time  `json:"nextRun"`
+	LastRun        *RunResult `json:"lastRun,omitempty"`
+	OverlapSkipped int        `json:"overlapSkipped"`
+}
+
+// newDaemon schedules p as configured. A StatusAddr of "off" disables the
+// status server.
+func newDaemon(p *Pipeline, cfg ScheduleConfig) (*Daemon, error) {
+	schedule, err := ParseSchedule(cfg.Cron, colombo)
+	if err != nil {
+		return nil, err
+	}
+	d := &Daemon{Pipeline: p, Schedule: schedule, StatusAddr: cfg.StatusAddr, ShutdownGrace: time.Duration(cfg.ShutdownGrace)}
+	if d.StatusAddr == "off" {
+		d.StatusAddr = ""
+	}
+	return d, nil
+}
+
+// start begins a run in the background unless one is already going
+func (d *Daemon) start(runCtx context.Context, trigger string) bool {
+	d.mu.Lock()
+	if d.stopping {
+		d.mu.Unlock()
+		return false
+	}
+	if d.running {
+		d.skipped++
+		d.mu.Unlock()
+		log.Printf("Skipping %s run: previous run still in progress.", trigger)
+		return false
+	}
+	d.running = true
+	d.started = time.Now()
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+		log.Printf("Starting %s run...", trigger)
+		p := *d.Pipeline
+		p.Trigger = trigger
+		result := p.Run(runCtx)
+		log.Printf("Run finished with exit code %d in %s.", result.ExitCode, result.End.Sub(result.Start).Round(time.Millisecond))
+
+		d.mu.Lock()
+		d.running = false
+		d.last = &result
+		d.mu.Unlock()
+	}()
+	return true
+}
+
+func (d *Daemon) status() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := DaemonStatus{
+		Schedule:       d.Schedule.Expr,
+		Timezone:       d.Schedule.Location.String(),
+		Running:        d.running,
+		NextRun:        d.next,
+		LastRun:        d.last,
+		OverlapSkipped: d.skipped,
+	}
+	if d.running {
+		started := d.started
+		st.RunningSince = &started
+	}
+	return st
+}
+
+func (d *Daemon) statusHandler(runCtx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.status())
+	})
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, r *http.Request) {
+		if !d.start(runCtx, "manual") {
+			http.Error(w, "a run is already in progress", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// Serve runs until ctx is cancelled (SIGTERM/SIGINT in main), then lets
+// any in-flight run finish within ShutdownGrace before cancelling it.
+func (d *Daemon) Serve(ctx context.Context) int {
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+
+	var server *http.Server
+	if d.StatusAddr != "" {
+		server = &http.Server{Addr: d.StatusAddr, Handler: d.statusHandler(runCtx)}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Status server stopped: %v", err)
+			}
+		}()
+		log.Printf("Status server listening on %s", d.StatusAddr)
+	}
+
+	for {
+		next := d.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Schedule %q never fires; exiting.", d.Schedule.Expr)
+			return exitConfig
+		}
+		d.mu.Lock()
+		d.next = next
+		d.mu.Unlock()
+		log.Printf("Next run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.shutdown(server, cancelRuns)
+			return exitOK
+		case <-timer.C:
+			d.start(runCtx, "scheduled")
+		}
+	}
+}
+
+func (d *Daemon) shutdown(server *http.Server, cancelRuns context.CancelFunc) {
+	log.Println("Shutting down...")
+	d.mu.Lock()
+	d.stopping = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d.ShutdownGrace):
+		log.Printf("Run still in progress after %s; cancelling it.", d.ShutdownGrace)
+		cancelRuns()
+		<-done
+	}
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+	log.Println("Scheduler stopped.")
+}
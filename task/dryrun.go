@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DryRunReport is everything a run would have sent and produced. Only the
+// backend's read-only dry-run endpoints are called to build it.
+type DryRunReport struct {
+	GeneratedAt   time.Time                  `json:"generatedAt"`
+	Source        string                     `json:"source"`
+	MarketPayload map[string]interface{}     `json:"marketPayload"`
+	IndexPayload  []map[string]interface{}   `json:"indexPayload,omitempty"`
+	MarketDiff    json.RawMessage            `json:"marketDiff,omitempty"` // POST /market/update?dryRun=true
+	Snapshots     map[string]json.RawMessage `json:"snapshots,omitempty"`  // uid -> snapshot that would be saved
+	Errors        []string                   `json:"errors,omitempty"`
+}
+
+// errDryRunIgnored means the backend answered a ?dryRun=true request as a
+// real write, as a backend older than the parameter would
+var errDryRunIgnored = errors.New("backend ignored dryRun=true and may have written; is it older than the task?")
+
+// honoredDryRun reports whether a 200 response body says nothing was
+// written: POST /market/update sets dryRun, POST /portfolio/snapshot says so
+// in its status
+func honoredDryRun(body []byte) bool {
+	var r struct {
+		DryRun bool   `json:"dryRun"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return false
+	}
+	return r.DryRun || strings.HasPrefix(r.Status, "Dry run")
+}
+
+// dryRun completes a run from the built market payload without writing
+// anything, and writes the report to p.DryRunOut (stdout when empty).
+func (p *Pipeline) dryRun(ctx context.Context, provider PriceProvider, marketData map[string]interface{}, result RunResult) RunResult {
+	report := DryRunReport{
+		GeneratedAt:   time.Now(),
+		Source:        provider.Name(),
+		MarketPayload: marketData,
+		Snapshots:     map[string]json.RawMessage{},
+	}
+	code := exitOK
+	fail := func(c int, format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		log.Print(msg)
+		report.Errors = append(report.Errors, msg)
+		if code == exitOK {
+			code = c
+		}
+	}
+
+	// What the backend would do with the update, against its current latest
+	snapshotPrices := marketData
+	updateURL := fmt.Sprintf("%s/market/update?dryRun=true&source=%s", p.BackendURL, url.QueryEscape(provider.Name()))
+	diff, err := p.postJSON(ctx, updateURL, marketData)
+	switch {
+	case err != nil:
+		fail(exitBackendFailure, "Market update dry run failed: %v", err)
+	case !honoredDryRun(diff):
+		// Going on would only write more: stop before any snapshot
+		fail(exitBackendFailure, "Aborting dry run: %v", errDryRunIgnored)
+		return p.finishDryRun(report, code, result)
+	default:
+		report.MarketDiff = diff
+		snapshotPrices = logMarketDiff(diff, marketData)
+	}
+
+	if ip, ok := provider.(IndexProvider); ok {
+		indices, err := ip.FetchIndices(ctx)
+		if err != nil {
+			log.Printf("Error fetching some index levels: %v", err)
+		}
+		report.IndexPayload = indexPayload(indices)
+	}
+
+	// The snapshot each user would get once the accepted prices are stored
+	var uids []string
+	if !p.NoSnapshots {
+		if uids, err = snapshotUIDs(ctx, p.HTTP, p.BackendURL, p.UIDs); err != nil {
+			fail(exitBackendFailure, "Error finding users to snapshot: %v", err)
+		}
+	}
+	previews, results := previewAll(ctx, p.HTTP, p.BackendURL, uids, snapshotPrices, p.Pool)
+	for _, r := range results {
+		if r.Err != nil {
+			result.Failed++
+			fail(exitPartial, "Snapshot dry run for %s failed: %v", r.UID, r.Err)
+			if errors.Is(r.Err, errDryRunIgnored) {
+				code = exitBackendFailure
+			}
+			continue
+		}
+		result.Snapshots++
+	}
+	report.Snapshots = previews
+	if result.Failed > 0 && result.Snapshots == 0 {
+		code = exitBackendFailure
+	}
+	log.Printf("Dry run complete: %d symbols, %d index levels, %d snapshots. Nothing was written.",
+		len(marketData), len(report.IndexPayload), len(report.Snapshots))
+	return p.finishDryRun(report, code, result)
+}
+
+// finishDryRun writes the report and sets the run's outcome
+func (p *Pipeline) finishDryRun(report DryRunReport, code int, result RunResult) RunResult {
+	if err := writeDryRunReport(p.DryRunOut, report); err != nil {
+		msg := fmt.Sprintf("Error writing dry-run report: %v", err)
+		log.Print(msg)
+		report.Errors = append(report.Errors, msg)
+		if code == exitOK {
+			code = exitConfig
+		}
+	}
+	result.ExitCode = code
+	if len(report.Errors) > 0 {
+		result.Error = report.Errors[0]
+	}
+	result.End = time.Now()
+	return result
+}
+
+// logMarketDiff summarises the backend's dry-run report and returns the
+// payload minus symbols that would be quarantined, which is what snapshots
+// would actually be valued with
+func logMarketDiff(diff json.RawMessage, marketData map[string]interface{}) map[string]interface{} {
+	var r struct {
+		Added     []string `json:"added"`
+		Unchanged []string `json:"unchanged"`
+		Rejected  []struct {
+			Symbol string `json:"symbol"`
+			Reason string `json:"reason"`
+		} `json:"rejected"`
+		Quarantined []struct {
+			Symbol  string  `json:"symbol"`
+			MovePct float64 `json:"movePct"`
+		} `json:"quarantined"`
+		Changes []struct {
+			Symbol        string  `json:"symbol"`
+			PreviousPrice float64 `json:"previousPrice"`
+			Price         float64 `json:"price"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(diff, &r); err != nil {
+		log.Printf("Could not read market dry-run report: %v", err)
+		return marketData
+	}
+
+	log.Printf("Would update market data: %d added, %d changed, %d unchanged, %d rejected, %d quarantined.",
+		len(r.Added), len(r.Changes), len(r.Unchanged), len(r.Rejected), len(r.Quarantined))
+	for _, s := range r.Added {
+		log.Printf("  + %s", s)
+	}
+	for _, ch := range r.Changes {
+		log.Printf("  ~ %s %.2f -> %.2f", ch.Symbol, ch.PreviousPrice, ch.Price)
+	}
+	for _, rej := range r.Rejected {
+		log.Printf("  ! %s rejected: %s", rej.Symbol, rej.Reason)
+	}
+
+	accepted := make(map[string]interface{}, len(marketData))
+	for k, v := range marketData {
+		accepted[k] = v
+	}
+	for _, q := range r.Quarantined {
+		log.Printf("  ? %s quarantined (%.2f%% move)", q.Symbol, q.MovePct)
+		delete(accepted, q.Symbol)
+	}
+	return accepted
+}
+
+// postJSON posts body and returns the raw JSON response of a 200
+func (p *Pipeline) postJSON(ctx context.Context, u string, body interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.HTTP.Do(ctx, "POST", u, data, jsonHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backend returned %d: %s", resp.StatusCode, string(respBody))
+	}
+	return json.RawMessage(respBody), nil
+}
+
+// writeDryRunReport writes indented JSON to path, or stdout when path is
+// empty or "-"
+func writeDryRunReport(path string, report DryRunReport) error {
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return err
+	}
+	log.Printf("Dry-run report written to %s", path)
+	return nil
+}
@@ -7,6 +7,7 @@ toolchain go1.24.11
 require (
 	cloud.google.com/go/firestore v1.20.0
 	firebase.google.com/go v3.13.0+incompatible
+	github.com/goccy/go-yaml v1.18.0
 	github.com/joho/godotenv v1.5.1
 	google.golang.org/api v0.259.0
 )
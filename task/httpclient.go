@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPClient wraps every outbound call the task makes with a per-attempt
+// timeout and exponential backoff with full jitter. Network errors and the
+// status codes in retryableStatus are retried; anything else is returned to
+// the caller on the first attempt.
+type HTTPClient struct {
+	Client      *http.Client // Timeout applies to each attempt
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Signer      *ServiceSigner // signs backend requests; nil sends them unsigned
+}
+
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooEarly:            true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// newHTTPClient builds the client from validated configuration
+func newHTTPClient(cfg HTTPConfig) *HTTPClient {
+	return &HTTPClient{
+		Client:      &http.Client{Timeout: time.Duration(cfg.Timeout)},
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.BackoffBase),
+		MaxDelay:    time.Duration(cfg.BackoffMax),
+	}
+}
+
+// Do sends the request, retrying as configured, and returns the last
+// response; the caller checks its status and closes its body. body may be
+// nil. POSTs are retried too: every backend endpoint the task calls is
+// safe to repeat.
+func (c *HTTPClient) Do(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, error) {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if c.Signer != nil {
+			c.Signer.Sign(req, body)
+		}
+
+		resp, err := c.Client.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+		case retryableStatus[resp.StatusCode] && attempt < c.MaxAttempts:
+			wait = retryAfter(resp)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s %s: status %d", method, url, resp.StatusCode)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= c.MaxAttempts {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, lastErr)
+		}
+		if backoff := c.backoff(attempt); backoff > wait {
+			wait = backoff
+		}
+		if wait > c.MaxDelay {
+			wait = c.MaxDelay
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ctx.Err(), lastErr)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff is a full-jitter delay before retry number attempt
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	ceiling := c.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.MaxDelay {
+		ceiling = c.MaxDelay
+	}
+	return rand.N(ceiling) + 1
+}
+
+// retryAfter honours a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 0
+}
+
+// jsonHeader is the header set for JSON request bodies
+var jsonHeader = http.Header{"Content-Type": {"application/json"}}
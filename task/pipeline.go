@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Process exit codes, so the scheduler can tell failures apart. They start
+// at 10 to stay clear of 1 (log.Fatal) and 2 (a Go runtime panic), so a
+// crash is never mistaken for a classified failure.
+const (
+	exitOK             = 0
+	exitConfig         = 10 // invalid configuration
+	exitSourceFailure  = 11 // price source unreachable or unreadable
+	exitBackendFailure = 12 // backend unreachable or rejected the update
+	exitPartial        = 13 // prices stored but some snapshots failed
+)
+
+// Pipeline is one scrape-and-snapshot run: fetch prices, post them to the
+// backend, post index levels, then snapshot every active user.
+type Pipeline struct {
+	HTTP        *HTTPClient
+	BackendURL  string
+	Provider    PriceProvider  // nil builds one from Sources
+	Sources     []SourceConfig // price sources in order of precedence
+	UIDs        []string       // users to snapshot; every active user when empty
+	Pool        SnapshotPool   // how users are snapshotted
+	Force       bool           // run even when the calendar says the market is shut
+	NoSnapshots bool           // stop once prices and indices are stored
+	DryRun      bool           // fetch and transform, then report instead of writing
+	DryRunOut   string         // dry-run report path; stdout when empty
+	Command     string         // recorded with the run; defaults to "run"
+	Trigger     string         // recorded with the run: scheduled, manual or cli
+}
+
+// Run outcomes as the backend records them
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeSkipped   = "skipped"
+	outcomePartial   = "partial"
+	outcomeFailed    = "failed"
+)
+
+// RunResult is the outcome of one Pipeline.Run, and the body of the
+// backend's POST /admin/task-runs
+type RunResult struct {
+	ID          string    `json:"id,omitempty"` // set when recorded
+	Command     string    `json:"command"`
+	Trigger     string    `json:"trigger,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	ExitCode    int       `json:"exitCode"`
+	Outcome     string    `json:"outcome,omitempty"` // set when recorded
+	Error       string    `json:"error,omitempty"`   // what ended the run
+	Skipped     string    `json:"skipped,omitempty"` // why nothing was done
+	Source      string    `json:"source,omitempty"`
+	Symbols     int       `json:"symbols"` // quotes fetched
+	Snapshots   int       `json:"snapshots"`
+	Failed      int       `json:"failedSnapshots"`
+	FailedUsers []string  `json:"failedUsers,omitempty"`
+	Errors      []string  `json:"errors,omitempty"` // everything that went wrong, fatal or not
+}
+
+// fail records a failure on the result and logs it
+func (r *RunResult) fail(code int, format string, args ...interface{}) RunResult {
+	r.ExitCode = code
+	r.Error = fmt.Sprintf(format, args...)
+	r.Errors = append(r.Errors, r.Error)
+	r.End = time.Now()
+	log.Print(r.Error)
+	return *r
+}
+
+// snapshotFailed records one user's failed snapshot and logs it
+func (r *RunResult) snapshotFailed(uid string, err error) {
+	r.userFailed("snapshot", uid, err)
+}
+
+// userFailed records that action failed for one user and logs it
+func (r *RunResult) userFailed(action, uid string, err error) {
+	r.Failed++
+	r.FailedUsers = append(r.FailedUsers, uid)
+	r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %v", action, uid, err))
+	log.Printf("  %s %s: FAILED: %v", action, uid, err)
+}
+
+// outcome classifies the run by its exit code
+func (r *RunResult) outcome() string {
+	switch {
+	case r.ExitCode == exitOK && r.Skipped != "":
+		return outcomeSkipped
+	case r.ExitCode == exitOK:
+		return outcomeSucceeded
+	case r.ExitCode == exitPartial:
+		return outcomePartial
+	}
+	return outcomeFailed
+}
+
+// Run executes the pipeline once and, unless it is a dry run, records the
+// outcome with the backend. It never exits the process; the exit code it
+// would map to is in the result.
+func (p *Pipeline) Run(ctx context.Context) RunResult {
+	result := p.run(ctx)
+	if !p.DryRun {
+		recordRun(ctx, p.HTTP, p.BackendURL, result)
+	}
+	return result
+}
+
+func (p *Pipeline) run(ctx context.Context) RunResult {
+	result := RunResult{Command: p.Command, Trigger: p.Trigger, Start: time.Now()}
+	if result.Command == "" {
+		result.Command = "run"
+	}
+
+	// Skip weekends and exchange holidays so they do not produce duplicate
+	// flat snapshots. Force runs anyway, e.g. for a late backfill.
+	if !p.Force {
+		day, err := fetchTradingDay(ctx, p.HTTP, p.BackendURL)
+		switch {
+		case err != nil:
+			log.Printf("Could not check trading calendar, running anyway: %v", err)
+		case !day.TradingDay:
+			log.Printf("%s is not a trading day (%s). Nothing to do.", day.Date, day.Reason)
+			result.Skipped = fmt.Sprintf("%s is not a trading day (%s)", day.Date, day.Reason)
+			result.End = time.Now()
+			return result
+		default:
+			log.Printf("%s is a trading day, session %s.", day.Date, day.Phase)
+		}
+	}
+
+	provider := p.Provider
+	if provider == nil {
+		var err error
+		if provider, err = newPriceProvider(p.Sources, p.HTTP); err != nil {
+			return result.fail(exitConfig, "Error configuring price provider: %v", err)
+		}
+	}
+
+	// 1. Fetch Quotes
+	log.Printf("Fetching prices from %s...", provider.Name())
+	quotes, err := provider.FetchQuotes(ctx)
+	result.Source = provider.Name() // with several sources, the one that answered
+	if err != nil {
+		return result.fail(exitSourceFailure, "Error fetching prices from %s: %v", provider.Name(), err)
+	}
+	if len(quotes) == 0 {
+		return result.fail(exitSourceFailure, "No data found in provider response.")
+	}
+	result.Symbols = len(quotes)
+	log.Printf("Received %d items. Preparing to send to backend...", len(quotes))
+
+	// 2. Prepare Market Data for Backend
+	marketData := marketPayload(quotes)
+	if p.DryRun {
+		return p.dryRun(ctx, provider, marketData, result)
+	}
+
+	// 3. Call Backend: Update Market Data
+	if code, err := p.postMarketData(ctx, provider.Name(), marketData); err != nil {
+		return result.fail(code, "Market data update failed: %v", err)
+	}
+
+	// 3b. Index levels, when the source reports them. Indices are not used
+	// for valuation, so a failure here does not stop the run.
+	if ip, ok := provider.(IndexProvider); ok {
+		postIndices(ctx, p.HTTP, ip, p.BackendURL)
+	}
+	if p.NoSnapshots {
+		result.End = time.Now()
+		return result
+	}
+
+	// 4. Call Backend: Snapshot every active user
+	uids, err := snapshotUIDs(ctx, p.HTTP, p.BackendURL, p.UIDs)
+	if err != nil {
+		return result.fail(exitBackendFailure, "Error finding users to snapshot: %v", err)
+	}
+	log.Printf("Snapshotting %d users, %d at a time...", len(uids), p.Pool.Concurrency)
+
+	for _, r := range snapshotAll(ctx, p.HTTP, p.BackendURL, uids, p.Pool) {
+		if r.Err != nil {
+			result.snapshotFailed(r.UID, r.Err)
+			continue
+		}
+		result.Snapshots++
+		log.Printf("  snapshot %s: ok", r.UID)
+	}
+	switch {
+	case result.Failed > 0 && result.Snapshots == 0:
+		return result.fail(exitBackendFailure, "All %d snapshots failed.", result.Failed)
+	case result.Failed > 0:
+		return result.fail(exitPartial, "%d of %d snapshots failed.", result.Failed, len(uids))
+	}
+	log.Printf("Portfolio snapshots saved for %d users.", len(uids))
+
+	result.ExitCode = exitOK
+	result.End = time.Now()
+	return result
+}
+
+// postMarketData sends POST /market/update and logs the per-symbol report.
+// The returned code classifies a failure.
+func (p *Pipeline) postMarketData(ctx context.Context, source string, marketData map[string]interface{}) (int, error) {
+	log.Println("Calling POST /market/update...")
+	jsonMarket, _ := json.Marshal(marketData)
+	updateURL := fmt.Sprintf("%s/market/update?source=%s", p.BackendURL, url.QueryEscape(source))
+	postResp, err := p.HTTP.Do(ctx, "POST", updateURL, jsonMarket, jsonHeader)
+	if err != nil {
+		return exitBackendFailure, err
+	}
+	defer postResp.Body.Close()
+	if postResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(postResp.Body)
+		return exitBackendFailure, fmt.Errorf("backend returned %d: %s", postResp.StatusCode, string(body))
+	}
+
+	var updateResult struct {
+		Added     []string `json:"added"`
+		Changed   []string `json:"changed"`
+		Unchanged []string `json:"unchanged"`
+		Rejected  []struct {
+			Symbol string `json:"symbol"`
+			Reason string `json:"reason"`
+		} `json:"rejected"`
+		Quarantined []struct {
+			Symbol        string  `json:"symbol"`
+			Price         float64 `json:"price"`
+			PreviousPrice float64 `json:"previousPrice"`
+			MovePct       float64 `json:"movePct"`
+		} `json:"quarantined"`
+	}
+	if err := json.NewDecoder(postResp.Body).Decode(&updateResult); err == nil {
+		log.Printf("Market data updated: %d added, %d changed, %d unchanged, %d rejected, %d quarantined.",
+			len(updateResult.Added), len(updateResult.Changed), len(updateResult.Unchanged), len(updateResult.Rejected), len(updateResult.Quarantined))
+		for _, r := range updateResult.Rejected {
+			log.Printf("  rejected %s: %s", r.Symbol, r.Reason)
+		}
+		for _, q := range updateResult.Quarantined {
+			log.Printf("  quarantined %s: %.2f -> %.2f (%.2f%%), awaiting review", q.Symbol, q.PreviousPrice, q.Price, q.MovePct)
+		}
+	} else {
+		log.Println("Market data updated successfully.")
+	}
+	return exitOK, nil
+}
+
+// postIndices fetches index levels and sends them to POST /market/indices,
+// logging rather than failing on errors
+func postIndices(ctx context.Context, httpClient *HTTPClient, ip IndexProvider, backendURL string) {
+	log.Println("Fetching index levels...")
+	indices, err := ip.FetchIndices(ctx)
+	if err != nil {
+		log.Printf("Error fetching some index levels: %v", err)
+	}
+	levels := indexPayload(indices)
+	if len(levels) == 0 {
+		log.Println("No index levels to send.")
+		return
+	}
+
+	log.Println("Calling POST /market/indices...")
+	body, _ := json.Marshal(levels)
+	resp, err := httpClient.Do(ctx, "POST", backendURL+"/market/indices", body, jsonHeader)
+	if err != nil {
+		log.Printf("Error updating index levels: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		log.Printf("Backend Index Update Failed: %s", string(msg))
+		return
+	}
+	log.Printf("Index levels updated (%d sent).", len(levels))
+}
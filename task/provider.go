@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Quote is one symbol's price as reported by a source
+type Quote struct {
+	Symbol        string
+	Name          string
+	Price         float64
+	PreviousClose float64 // 0 when unknown
+	ChangePct     float64
+	Timestamp     time.Time
+
+	// Daily bar; 0 when the source does not report a field
+	Open     float64
+	High     float64
+	Low      float64
+	Volume   float64 // shares traded
+	Trades   float64 // number of trades
+	Turnover float64
+}
+
+// barFields maps the optional bar fields of a quote to their payload keys
+func (q Quote) barFields() map[string]float64 {
+	return map[string]float64{
+		"open":     q.Open,
+		"high":     q.High,
+		"low":      q.Low,
+		"volume":   q.Volume,
+		"trades":   q.Trades,
+		"turnover": q.Turnover,
+	}
+}
+
+// PriceProvider is a source of market prices. The orchestration in main only
+// sees this interface, so adding a source never touches the pipeline itself.
+type PriceProvider interface {
+	// Name identifies the source in logs
+	Name() string
+	FetchQuotes(ctx context.Context) ([]Quote, error)
+}
+
+// newPriceProvider builds the configured sources. With several, they are
+// tried in order and the first to return quotes is used.
+func newPriceProvider(sources []SourceConfig, httpClient *HTTPClient) (PriceProvider, error) {
+	var providers []PriceProvider
+	for _, src := range sources {
+		switch src.Type {
+		case "cse":
+			baseURL := src.BaseURL
+			if baseURL == "" {
+				baseURL = defaultCSEBaseURL
+			}
+			providers = append(providers, &CSEProvider{BaseURL: baseURL, RecordTo: src.RecordFixture, HTTP: httpClient})
+		case "file":
+			providers = append(providers, &FileProvider{Path: src.Path})
+		case "fixture":
+			providers = append(providers, &FixtureProvider{Path: src.Path})
+		default:
+			return nil, fmt.Errorf("unknown price source %q (want cse, file or fixture)", src.Type)
+		}
+	}
+	switch len(providers) {
+	case 0:
+		return nil, errors.New("no price sources configured")
+	case 1:
+		return providers[0], nil
+	}
+	return &FallbackProvider{Providers: providers}, nil
+}
+
+// FallbackProvider tries each source in order of precedence and uses the
+// first that returns any quotes. Build a new one per run.
+type FallbackProvider struct {
+	Providers []PriceProvider
+	used      PriceProvider
+}
+
+// Name is the source that supplied the quotes once fetched, or the list
+// of candidates before
+func (p *FallbackProvider) Name() string {
+	if p.used != nil {
+		return p.used.Name()
+	}
+	names := make([]string, len(p.Providers))
+	for i, src := range p.Providers {
+		names[i] = src.Name()
+	}
+	return strings.Join(names, ", then ")
+}
+
+func (p *FallbackProvider) FetchQuotes(ctx context.Context) ([]Quote, error) {
+	var errs []error
+	for _, src := range p.Providers {
+		quotes, err := src.FetchQuotes(ctx)
+		if err == nil && len(quotes) > 0 {
+			p.used = src
+			return quotes, nil
+		}
+		if err == nil {
+			err = errors.New("no quotes")
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("Price source %s failed, trying the next: %v", src.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", src.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// FetchIndices reads index levels from the source that supplied the quotes,
+// if it reports them
+func (p *FallbackProvider) FetchIndices(ctx context.Context) ([]IndexQuote, error) {
+	if ip, ok := p.used.(IndexProvider); ok {
+		return ip.FetchIndices(ctx)
+	}
+	return nil, nil
+}
+
+// marketPayload converts quotes into the body of POST /market/update. Each
+// symbol carries its previous close so the backend can report day change,
+// and whatever part of the day's bar the source reported.
+func marketPayload(quotes []Quote) map[string]interface{} {
+	marketData := make(map[string]interface{})
+	for _, q := range quotes {
+		if q.Symbol == "" || q.Price <= 0 {
+			continue
+		}
+		entry := map[string]interface{}{
+			"price":     q.Price,
+			"changePct": q.ChangePct,
+			"timestamp": q.Timestamp.UTC().Format(time.RFC3339),
+		}
+		if q.Name != "" {
+			entry["name"] = q.Name
+		}
+		if q.PreviousClose > 0 {
+			entry["previousClose"] = q.PreviousClose
+		}
+		for k, v := range q.barFields() {
+			if v > 0 {
+				entry[k] = v
+			}
+		}
+		marketData[q.Symbol] = entry
+	}
+	return marketData
+}
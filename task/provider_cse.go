@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultCSEBaseURL = "https://www.cse.lk"
+
+type CSETradeSummary struct {
+	ReqTradeSummery []struct {
+		Symbol           string  `json:"symbol"`
+		Price            float64 `json:"price"`
+		ClosingPrice     float64 `json:"closingPrice"`
+		PreviousClose    float64 `json:"previousClose"`
+		Name             string  `json:"name"`
+		PercentageChange float64 `json:"percentageChange"`
+		LastTradedTime   int64   `json:"lastTradedTime"` // epoch millis
+		Open             float64 `json:"open"`
+		High             float64 `json:"high"`
+		Low              float64 `json:"low"`
+		ShareVolume      float64 `json:"sharevolume"`
+		TradeVolume      float64 `json:"tradevolume"`
+		Turnover         float64 `json:"turnover"`
+	} `json:"reqTradeSummery"`
+}
+
+// CSEProvider fetches the live trade summary from the Colombo Stock Exchange
+type CSEProvider struct {
+	BaseURL  string
+	RecordTo string // if set, the raw response is written here for FixtureProvider
+	HTTP     *HTTPClient
+}
+
+func (p *CSEProvider) Name() string { return "cse" }
+
+func (p *CSEProvider) FetchQuotes(ctx context.Context) ([]Quote, error) {
+	body, err := p.post(ctx, "/api/tradeSummary")
+	if err != nil {
+		return nil, err
+	}
+	if p.RecordTo != "" {
+		if err := os.WriteFile(p.RecordTo, body, 0o644); err != nil {
+			return nil, fmt.Errorf("recording fixture: %w", err)
+		}
+	}
+	return quotesFromTradeSummary(body, time.Now())
+}
+
+// post issues the form of request the cse.lk web app sends
+func (p *CSEProvider) post(ctx context.Context, path string) ([]byte, error) {
+	payload := map[string]interface{}{
+		"headers": map[string]interface{}{
+			"normalizedNames": map[string]interface{}{},
+			"lazyUpdate":      nil,
+		},
+	}
+	jsonPayload, _ := json.Marshal(payload)
+
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"User-Agent":   {"Mozilla/5.0 (Go/Task)"},
+		"Origin":       {"https://www.cse.lk"},
+		"Referer":      {"https://www.cse.lk/"},
+	}
+	resp, err := p.HTTP.Do(ctx, "POST", p.BaseURL+path, jsonPayload, header)
+	if err != nil {
+		return nil, fmt.Errorf("fetching CSE data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CSE API error: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// quotesFromTradeSummary decodes a tradeSummary response body. fetchedAt
+// stands in for symbols CSE reports no last-trade time for.
+func quotesFromTradeSummary(body []byte, fetchedAt time.Time) ([]Quote, error) {
+	var data CSETradeSummary
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %w", err)
+	}
+
+	quotes := make([]Quote, 0, len(data.ReqTradeSummery))
+	for _, stock := range data.ReqTradeSummery {
+		finalPrice := stock.Price
+		if finalPrice <= 0 && stock.ClosingPrice > 0 {
+			finalPrice = stock.ClosingPrice
+		}
+		if stock.Symbol == "" {
+			continue
+		}
+
+		// Source timestamp: the last trade if CSE reports one, else when we fetched
+		sourceTime := fetchedAt
+		if stock.LastTradedTime > 0 {
+			sourceTime = time.UnixMilli(stock.LastTradedTime)
+		}
+
+		quotes = append(quotes, Quote{
+			Symbol:        stock.Symbol,
+			Name:          stock.Name,
+			Price:         finalPrice,
+			PreviousClose: previousClose(stock.PreviousClose, finalPrice, stock.PercentageChange),
+			ChangePct:     stock.PercentageChange,
+			Open:          stock.Open,
+			High:          stock.High,
+			Low:           stock.Low,
+			Volume:        stock.ShareVolume,
+			Trades:        stock.TradeVolume,
+			Turnover:      stock.Turnover,
+			Timestamp:     sourceTime,
+		})
+	}
+	return quotes, nil
+}
+
+// previousClose prefers the value CSE reports and otherwise backs it out of
+// today's percentage change. Returns 0 when neither is usable.
+func previousClose(reported, price, pctChange float64) float64 {
+	if reported > 0 {
+		return reported
+	}
+	if price <= 0 || pctChange <= -100 {
+		return 0
+	}
+	return math.Round(price/(1+pctChange/100)*100) / 100
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stubCSE serves body as the tradeSummary response, checking the request
+// has the shape the cse.lk API expects
+func stubCSE(t *testing.T, status int, body string) *CSEProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/tradeSummary" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	httpClient := &HTTPClient{Client: srv.Client(), MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return &CSEProvider{BaseURL: srv.URL, HTTP: httpClient}
+}
+
+func TestCSEProviderFetchQuotes(t *testing.T) {
+	p := stubCSE(t, http.StatusOK, `{"reqTradeSummery": [
+		{"symbol": "JKH.N0000", "name": "John Keells", "price": 200.5, "previousClose": 198, "percentageChange": 1.26,
+		 "lastTradedTime": 1772441100000, "open": 199, "high": 201, "low": 198.5,
+		 "sharevolume": 12000, "tradevolume": 85, "turnover": 2406000},
+		{"symbol": "DIAL.N0000", "price": 12.4, "percentageChange": -3.125, "lastTradedTime": 1772441100000},
+		{"symbol": "LOSS.N0000", "price": 5, "percentageChange": -100, "lastTradedTime": 1772441100000}
+	]}`)
+
+	quotes, err := p.FetchQuotes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	traded := time.UnixMilli(1772441100000)
+	want := []Quote{
+		{
+			Symbol: "JKH.N0000", Name: "John Keells", Price: 200.5, PreviousClose: 198, ChangePct: 1.26, Timestamp: traded,
+			Open: 199, High: 201, Low: 198.5, Volume: 12000, Trades: 85, Turnover: 2406000,
+		},
+		// no previous close reported: backed out of the percentage change
+		{Symbol: "DIAL.N0000", Price: 12.4, PreviousClose: 12.8, ChangePct: -3.125, Timestamp: traded},
+		// a change of -100% leaves nothing to back out
+		{Symbol: "LOSS.N0000", Price: 5, ChangePct: -100, Timestamp: traded},
+	}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("quotes:\n got %+v\nwant %+v", quotes, want)
+	}
+}
+
+func TestCSEProviderError(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusServiceUnavailable, "down"},
+		{"not found", http.StatusNotFound, ""},
+		{"malformed body", http.StatusOK, "<html>"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := stubCSE(t, tt.status, tt.body).FetchQuotes(context.Background()); err == nil {
+				t.Error("want an error, got none")
+			}
+		})
+	}
+}
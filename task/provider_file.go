@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileProvider reads prices from a local file, for offline runs and for
+// securities no exchange feed covers. Two formats are accepted, chosen by
+// extension:
+//
+//	.json  an object of symbol -> price, or an array of
+//	       {"symbol", "price", "previousClose", "changePct", "name",
+//	        "open", "high", "low", "volume", "trades", "turnover"}
+//	.csv   header row naming at least symbol and price; the other fields
+//	       above are optional columns
+type FileProvider struct {
+	Path string
+}
+
+func (p *FileProvider) Name() string { return "file:" + p.Path }
+
+func (p *FileProvider) FetchQuotes(ctx context.Context) ([]Quote, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	asOf := info.ModTime()
+
+	switch strings.ToLower(filepath.Ext(p.Path)) {
+	case ".json":
+		return quotesFromJSON(data, asOf)
+	case ".csv":
+		return quotesFromCSV(data, asOf)
+	}
+	return nil, fmt.Errorf("%s: unsupported price file type (want .json or .csv)", p.Path)
+}
+
+func quotesFromJSON(data []byte, asOf time.Time) ([]Quote, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var prices map[string]float64
+		if err := json.Unmarshal(trimmed, &prices); err != nil {
+			return nil, fmt.Errorf("decoding price map: %w", err)
+		}
+		quotes := make([]Quote, 0, len(prices))
+		for symbol, price := range prices {
+			quotes = append(quotes, Quote{Symbol: symbol, Price: price, Timestamp: asOf})
+		}
+		return quotes, nil
+	}
+
+	var rows []struct {
+		Symbol        string  `json:"symbol"`
+		Name          string  `json:"name"`
+		Price         float64 `json:"price"`
+		PreviousClose float64 `json:"previousClose"`
+		ChangePct     float64 `json:"changePct"`
+		Open          float64 `json:"open"`
+		High          float64 `json:"high"`
+		Low           float64 `json:"low"`
+		Volume        float64 `json:"volume"`
+		Trades        float64 `json:"trades"`
+		Turnover      float64 `json:"turnover"`
+	}
+	if err := json.Unmarshal(trimmed, &rows); err != nil {
+		return nil, fmt.Errorf("decoding price list: %w", err)
+	}
+	quotes := make([]Quote, 0, len(rows))
+	for _, r := range rows {
+		quotes = append(quotes, Quote{
+			Symbol:        r.Symbol,
+			Name:          r.Name,
+			Price:         r.Price,
+			PreviousClose: r.PreviousClose,
+			ChangePct:     r.ChangePct,
+			Open:          r.Open,
+			High:          r.High,
+			Low:           r.Low,
+			Volume:        r.Volume,
+			Trades:        r.Trades,
+			Turnover:      r.Turnover,
+			Timestamp:     asOf,
+		})
+	}
+	return quotes, nil
+}
+
+func quotesFromCSV(data []byte, asOf time.Time) ([]Quote, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int)
+	for i, h := range records[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["symbol"]; !ok {
+		return nil, fmt.Errorf("CSV header must include symbol")
+	}
+	if _, ok := col["price"]; !ok {
+		return nil, fmt.Errorf("CSV header must include price")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	number := func(row []string, name string, line int) (float64, error) {
+		v := field(row, name)
+		if v == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %s %q is not a number", line, name, v)
+		}
+		return f, nil
+	}
+
+	quotes := make([]Quote, 0, len(records)-1)
+	for i, row := range records[1:] {
+		line := i + 2
+		q := Quote{Symbol: field(row, "symbol"), Name: field(row, "name"), Timestamp: asOf}
+		if q.Price, err = number(row, "price", line); err != nil {
+			return nil, err
+		}
+		if q.PreviousClose, err = number(row, "previousclose", line); err != nil {
+			return nil, err
+		}
+		if q.ChangePct, err = number(row, "changepct", line); err != nil {
+			return nil, err
+		}
+		for name, dst := range map[string]*float64{
+			"open": &q.Open, "high": &q.High, "low": &q.Low,
+			"volume": &q.Volume, "trades": &q.Trades, "turnover": &q.Turnover,
+		} {
+			if *dst, err = number(row, name, line); err != nil {
+				return nil, err
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
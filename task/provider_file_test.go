@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writePriceFile writes a price file with a known modification time, which
+// FileProvider and FixtureProvider use as the quote time
+func writePriceFile(t *testing.T, name, content string, modTime time.Time) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func fetchPayload(t *testing.T, p PriceProvider) map[string]interface{} {
+	t.Helper()
+	quotes, err := p.FetchQuotes(context.Background())
+	if err != nil {
+		t.Fatalf("%s: %v", p.Name(), err)
+	}
+	return marketPayload(quotes)
+}
+
+func TestFileProviderPayload(t *testing.T) {
+	modTime := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	stamp := "2026-03-02T09:00:00Z"
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]interface{}
+	}{
+		{
+			name:    "json map",
+			file:    "prices.json",
+			content: `{"JKH.N0000": 200.5, "COMB.N0000": 0}`,
+			want: map[string]interface{}{
+				"JKH.N0000": map[string]interface{}{"price": 200.5, "changePct": 0.0, "timestamp": stamp},
+			},
+		},
+		{
+			name: "json list",
+			file: "prices.json",
+			content: `[
+				{"symbol": "JKH.N0000", "name": "John Keells", "price": 200.5, "previousClose": 198, "changePct": 1.26,
+				 "open": 199, "high": 201, "low": 198.5, "volume": 12000, "trades": 85, "turnover": 2406000},
+				{"symbol": "", "price": 10},
+				{"symbol": "DIAL.N0000", "price": 12.4}
+			]`,
+			want: map[string]interface{}{
+				"JKH.N0000": map[string]interface{}{
+					"price": 200.5, "changePct": 1.26, "timestamp": stamp, "name": "John Keells", "previousClose": 198.0,
+					"open": 199.0, "high": 201.0, "low": 198.5, "volume": 12000.0, "trades": 85.0, "turnover": 2406000.0,
+				},
+				"DIAL.N0000": map[string]interface{}{"price": 12.4, "changePct": 0.0, "timestamp": stamp},
+			},
+		},
+		{
+			name: "csv",
+			file: "close.csv",
+			content: "Symbol,Price,PreviousClose,ChangePct,Volume\n" +
+				"JKH.N0000, 200.5 ,198,1.26,12000\n" +
+				"DIAL.N0000,12.4,,,\n",
+			want: map[string]interface{}{
+				"JKH.N0000": map[string]interface{}{
+					"price": 200.5, "changePct": 1.26, "timestamp": stamp, "previousClose": 198.0, "volume": 12000.0,
+				},
+				"DIAL.N0000": map[string]interface{}{"price": 12.4, "changePct": 0.0, "timestamp": stamp},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePriceFile(t, tt.file, tt.content, modTime)
+			got := fetchPayload(t, &FileProvider{Path: path})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payload:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderErrors(t *testing.T) {
+	modTime := time.Now()
+	tests := []struct {
+		name, file, content string
+	}{
+		{"unsupported extension", "prices.txt", "JKH.N0000 200.5"},
+		{"csv without price column", "close.csv", "symbol,close\nJKH.N0000,200.5\n"},
+		{"csv with a bad number", "close.csv", "symbol,price\nJKH.N0000,abc\n"},
+		{"malformed json", "prices.json", `{"JKH.N0000": "200.5"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePriceFile(t, tt.file, tt.content, modTime)
+			if _, err := (&FileProvider{Path: path}).FetchQuotes(context.Background()); err == nil {
+				t.Error("want an error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os"
+)
+
+// FixtureProvider replays a tradeSummary response recorded with
+// CSE_RECORD_FIXTURE, exercising the same decoding as the live source
+// without touching the network.
+type FixtureProvider struct {
+	Path string
+}
+
+func (p *FixtureProvider) Name() string { return "fixture:" + p.Path }
+
+func (p *FixtureProvider) FetchQuotes(ctx context.Context) ([]Quote, error) {
+	body, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	// The recording time stands in for symbols without a last-trade time
+	return quotesFromTradeSummary(body, info.ModTime())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFixtureProviderPayload(t *testing.T) {
+	recordedAt := time.Date(2026, 3, 2, 9, 15, 0, 0, time.UTC)
+	path := writePriceFile(t, "tradeSummary.json", `{"reqTradeSummery": [
+		{"symbol": "JKH.N0000", "name": "John Keells", "price": 200.5, "previousClose": 198, "percentageChange": 1.26,
+		 "lastTradedTime": 1772441100000, "open": 199, "high": 201, "low": 198.5,
+		 "sharevolume": 12000, "tradevolume": 85, "turnover": 2406000},
+		{"symbol": "DIAL.N0000", "price": 0, "closingPrice": 12.4, "percentageChange": -3.125},
+		{"symbol": "", "price": 10},
+		{"symbol": "HALT.N0000", "price": 0}
+	]}`, recordedAt)
+
+	want := map[string]interface{}{
+		"JKH.N0000": map[string]interface{}{
+			"price": 200.5, "changePct": 1.26, "timestamp": "2026-03-02T08:45:00Z", "name": "John Keells", "previousClose": 198.0,
+			"open": 199.0, "high": 201.0, "low": 198.5, "volume": 12000.0, "trades": 85.0, "turnover": 2406000.0,
+		},
+		// The closing price stands in for the missing price and the previous
+		// close is backed out of the change. With no last-trade time, the
+		// timestamp is the fixture file's recording time, not a trade time.
+		"DIAL.N0000": map[string]interface{}{
+			"price": 12.4, "changePct": -3.125, "timestamp": "2026-03-02T09:15:00Z", "previousClose": 12.8,
+		},
+	}
+	got := fetchPayload(t, &FixtureProvider{Path: path})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payload:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IndexQuote is one index level as reported by a source
+type IndexQuote struct {
+	Code      string
+	Name      string
+	Value     float64
+	Change    float64
+	ChangePct float64
+	High      float64 // 0 when unknown
+	Low       float64
+	Timestamp time.Time
+}
+
+// IndexProvider is implemented by price sources that also report index
+// levels. It is optional: main only posts indices when the configured
+// provider has them.
+type IndexProvider interface {
+	FetchIndices(ctx context.Context) ([]IndexQuote, error)
+}
+
+// cseIndex is the shape of aspiData and snpData
+type cseIndex struct {
+	Value      float64 `json:"value"`
+	Change     float64 `json:"change"`
+	Percentage float64 `json:"percentage"`
+	HighValue  float64 `json:"highValue"`
+	LowValue   float64 `json:"lowValue"`
+	Timestamp  int64   `json:"timestamp"` // epoch millis
+}
+
+// cseSector is one element of allSectors
+type cseSector struct {
+	IndexCode  string  `json:"indexCode"`
+	IndexName  string  `json:"indexName"`
+	IndexValue float64 `json:"indexValue"`
+	Change     float64 `json:"change"`
+	Percentage float64 `json:"percentage"`
+	HighValue  float64 `json:"highValue"`
+	LowValue   float64 `json:"lowValue"`
+	TimeStamp  int64   `json:"timeStamp"` // epoch millis
+}
+
+// FetchIndices reads the headline indices and every sector index. Each
+// endpoint is independent; a failing one is reported but does not drop the
+// others.
+func (p *CSEProvider) FetchIndices(ctx context.Context) ([]IndexQuote, error) {
+	fetchedAt := time.Now()
+	var quotes []IndexQuote
+	var failed []string
+
+	for _, headline := range []struct{ path, code, name string }{
+		{"/api/aspiData", "ASPI", "All Share Price Index"},
+		{"/api/snpData", "SPSL20", "S&P Sri Lanka 20"},
+	} {
+		body, err := p.post(ctx, headline.path)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", headline.code, err))
+			continue
+		}
+		var idx cseIndex
+		if err := json.Unmarshal(body, &idx); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: decoding JSON: %v", headline.code, err))
+			continue
+		}
+		quotes = append(quotes, IndexQuote{
+			Code:      headline.code,
+			Name:      headline.name,
+			Value:     idx.Value,
+			Change:    idx.Change,
+			ChangePct: idx.Percentage,
+			High:      idx.HighValue,
+			Low:       idx.LowValue,
+			Timestamp: epochOr(idx.Timestamp, fetchedAt),
+		})
+	}
+
+	if body, err := p.post(ctx, "/api/allSectors"); err != nil {
+		failed = append(failed, fmt.Sprintf("sectors: %v", err))
+	} else {
+		var sectors []cseSector
+		if err := json.Unmarshal(body, &sectors); err != nil {
+			failed = append(failed, fmt.Sprintf("sectors: decoding JSON: %v", err))
+		}
+		for _, s := range sectors {
+			if s.IndexCode == "" {
+				continue
+			}
+			quotes = append(quotes, IndexQuote{
+				Code:      s.IndexCode,
+				Name:      s.IndexName,
+				Value:     s.IndexValue,
+				Change:    s.Change,
+				ChangePct: s.Percentage,
+				High:      s.HighValue,
+				Low:       s.LowValue,
+				Timestamp: epochOr(s.TimeStamp, fetchedAt),
+			})
+		}
+	}
+
+	if len(failed) > 0 {
+		return quotes, fmt.Errorf("fetching indices: %s", strings.Join(failed, "; "))
+	}
+	return quotes, nil
+}
+
+func epochOr(millis int64, fallback time.Time) time.Time {
+	if millis > 0 {
+		return time.UnixMilli(millis)
+	}
+	return fallback
+}
+
+// indexPayload converts index quotes into the body of POST /market/indices
+func indexPayload(quotes []IndexQuote) []map[string]interface{} {
+	levels := make([]map[string]interface{}, 0, len(quotes))
+	for _, q := range quotes {
+		if q.Code == "" || q.Value <= 0 {
+			continue
+		}
+		level := map[string]interface{}{
+			"code":      q.Code,
+			"value":     q.Value,
+			"change":    q.Change,
+			"changePct": q.ChangePct,
+			"timestamp": q.Timestamp.UTC().Format(time.RFC3339),
+		}
+		if q.Name != "" {
+			level["name"] = q.Name
+		}
+		if q.High > 0 {
+			level["high"] = q.High
+		}
+		if q.Low > 0 {
+			level["low"] = q.Low
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// newRunID is the run's start time, for readable ordering, and a random
+// suffix so runs started in the same second stay apart
+func newRunID(start time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// recordRun sends a finished run to POST /admin/task-runs. It runs even
+// when ctx was cancelled by a shutdown, since that is exactly the kind of
+// run worth recording, and only logs if the backend cannot take it. The
+// run gets its id here, so a retried POST overwrites the same record.
+func recordRun(ctx context.Context, httpClient *HTTPClient, backendURL string, result RunResult) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	if result.ID == "" {
+		result.ID = newRunID(result.Start)
+	}
+	result.Outcome = result.outcome()
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error encoding run record: %v", err)
+		return
+	}
+	resp, err := httpClient.Do(ctx, "POST", backendURL+"/admin/task-runs", body, jsonHeader)
+	if err != nil {
+		log.Printf("Error recording run: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		log.Printf("Backend did not record run: %d %s", resp.StatusCode, string(msg))
+	}
+}
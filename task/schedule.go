@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a standard five-field cron expression
+//
+//	minute hour day-of-month month day-of-week
+//
+// evaluated in a fixed location. Each field accepts *, numbers, ranges
+// (1-5), lists (1,15) and steps (*/15, 0-30/10). As in cron, when both
+// day-of-month and day-of-week are restricted a day matching either runs.
+type Schedule struct {
+	Expr     string
+	Location *time.Location
+
+	minute, hour, dom, month, dow [64]bool
+	domAny, dowAny                bool
+}
+
+// defaultSchedule runs 15 minutes after the 14:30 close, Monday to Friday.
+// Holidays are skipped by the pipeline's calendar check.
+const defaultSchedule = "45 14 * * 1-5"
+
+// ParseSchedule parses expr for evaluation in loc
+func ParseSchedule(expr string, loc *time.Location) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: want 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+	s := &Schedule{Expr: expr, Location: loc}
+	specs := []struct {
+		name     string
+		set      *[64]bool
+		min, max int
+	}{
+		{"minute", &s.minute, 0, 59},
+		{"hour", &s.hour, 0, 23},
+		{"day of month", &s.dom, 1, 31},
+		{"month", &s.month, 1, 12},
+		{"day of week", &s.dow, 0, 7},
+	}
+	for i, spec := range specs {
+		if err := parseCronField(fields[i], spec.set, spec.min, spec.max); err != nil {
+			return nil, fmt.Errorf("schedule %q: %s: %w", expr, spec.name, err)
+		}
+	}
+	// 7 is Sunday too
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+func parseCronField(field string, set *[64]bool, min, max int) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return fmt.Errorf("bad step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("bad range %q", rangePart)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return fmt.Errorf("bad value %q", rangePart)
+			}
+			lo, hi = n, n
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("%q outside %d-%d", rangePart, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first minute strictly after t that the schedule matches.
+// The zero time means nothing matches within the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
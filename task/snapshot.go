@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotResult is the outcome of one user's snapshot
+type SnapshotResult struct {
+	UID string
+	Err error
+}
+
+// snapshotUIDs returns the users to snapshot: the configured list when
+// there is one, otherwise every active user the backend knows about.
+func snapshotUIDs(ctx context.Context, httpClient *HTTPClient, backendURL string, configured []string) ([]string, error) {
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	resp, err := httpClient.Do(ctx, "GET", backendURL+"/admin/users", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("listing users: %d %s", resp.StatusCode, string(body))
+	}
+	var list struct {
+		Users []string `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decoding user list: %w", err)
+	}
+	return list.Users, nil
+}
+
+// snapshotUser triggers POST /portfolio/snapshot for one user
+func snapshotUser(ctx context.Context, httpClient *HTTPClient, backendURL, uid string) error {
+	u := fmt.Sprintf("%s/portfolio/snapshot?uid=%s", backendURL, url.QueryEscape(uid))
+	resp, err := httpClient.Do(ctx, "POST", u, nil, jsonHeader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// previewSnapshot returns the snapshot POST /portfolio/snapshot would save
+// for uid without saving it. prices, in the POST /market/update format,
+// values the portfolio as if they had been stored; nil uses stored prices.
+func previewSnapshot(ctx context.Context, httpClient *HTTPClient, backendURL, uid string, prices map[string]interface{}) (json.RawMessage, error) {
+	var body []byte
+	if prices != nil {
+		var err error
+		if body, err = json.Marshal(prices); err != nil {
+			return nil, err
+		}
+	}
+	u := fmt.Sprintf("%s/portfolio/snapshot?dryRun=true&uid=%s", backendURL, url.QueryEscape(uid))
+	resp, err := httpClient.Do(ctx, "POST", u, body, jsonHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if !honoredDryRun(respBody) {
+		return nil, errDryRunIgnored
+	}
+	var preview struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &preview); err != nil || preview.Data == nil {
+		return json.RawMessage(respBody), nil
+	}
+	return preview.Data, nil
+}
+
+// SnapshotPool bounds how many users are processed at once and how long
+// any one of them may take, retries included
+type SnapshotPool struct {
+	Concurrency int
+	Timeout     time.Duration
+}
+
+// each calls fn for every uid on at most Concurrency workers and returns
+// the outcomes in uid order. One user's failure never stops the others;
+// once ctx is cancelled, users not yet started fail with its error.
+func (pool SnapshotPool) each(ctx context.Context, uids []string, fn func(ctx context.Context, uid string) error) []SnapshotResult {
+	results := make([]SnapshotResult, len(uids))
+	workers := pool.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(uids) {
+		workers = len(uids)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = SnapshotResult{UID: uids[i], Err: pool.call(ctx, uids[i], fn)}
+			}
+		}()
+	}
+	for i, uid := range uids {
+		if ctx.Err() != nil {
+			results[i] = SnapshotResult{UID: uid, Err: ctx.Err()}
+			continue
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			results[i] = SnapshotResult{UID: uid, Err: ctx.Err()}
+		}
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+func (pool SnapshotPool) call(ctx context.Context, uid string, fn func(context.Context, string) error) error {
+	if pool.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pool.Timeout)
+		defer cancel()
+	}
+	return fn(ctx, uid)
+}
+
+// previewAll previews every user's snapshot through the pool. The returned
+// map holds the successful previews; results has every outcome. If the
+// backend turns out to ignore dryRun, no further users are started.
+func previewAll(ctx context.Context, httpClient *HTTPClient, backendURL string, uids []string, prices map[string]interface{}, pool SnapshotPool) (map[string]json.RawMessage, []SnapshotResult) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	previews := make(map[string]json.RawMessage, len(uids))
+	results := pool.each(ctx, uids, func(ctx context.Context, uid string) error {
+		snap, err := previewSnapshot(ctx, httpClient, backendURL, uid, prices)
+		if errors.Is(err, errDryRunIgnored) {
+			cancel()
+		}
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		previews[uid] = snap
+		mu.Unlock()
+		return nil
+	})
+	return previews, results
+}
+
+// snapshotAll snapshots every user through the pool
+func snapshotAll(ctx context.Context, httpClient *HTTPClient, backendURL string, uids []string, pool SnapshotPool) []SnapshotResult {
+	return pool.each(ctx, uids, func(ctx context.Context, uid string) error {
+		return snapshotUser(ctx, httpClient, backendURL, uid)
+	})
+}